@@ -5,31 +5,78 @@ import (
 	"os"
 
 	"gophkeeper/internal/actions"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/storage"
 
 	"github.com/urfave/cli/v2"
 )
 
-func Init() storage.Storage {
-	storage.Init()
-	return storage.NewMemoryStorage()
+// connectionFlags - global flags of the server connection
+func connectionFlags() []cli.Flag {
+	def := config.DefaultClient()
+	return []cli.Flag{
+		&cli.StringFlag{Name: "address", Value: def.Address, Usage: "server address", EnvVars: []string{"ADDRESS"}},
+		&cli.StringFlag{Name: "ca", Usage: "CA bundle of the server certificate, system roots are used when empty", EnvVars: []string{"TLS_CA"}},
+		&cli.StringFlag{Name: "pin", Usage: "base64 SHA-256 of the server public key", EnvVars: []string{"TLS_PIN"}},
+		&cli.StringFlag{Name: "cert", Usage: "client certificate for mutual TLS", EnvVars: []string{"TLS_CERT"}},
+		&cli.StringFlag{Name: "key", Usage: "client key for mutual TLS", EnvVars: []string{"TLS_KEY"}},
+		&cli.BoolFlag{Name: "insecure", Usage: "connect without TLS", EnvVars: []string{"INSECURE"}},
+		&cli.StringFlag{Name: "vault-dir", Usage: "directory of local vault files, working directory when empty", EnvVars: []string{"VAULT_DIR"}},
+		&cli.StringFlag{Name: "otp", Usage: "two-factor authentication code, asked interactively when empty"},
+	}
+}
+
+// connect - dials the server with configuration from global flags
+func connect(ctx *cli.Context) error {
+	storage.SecondFactor = func() (string, error) {
+		if code := ctx.String("otp"); code != "" {
+			return code, nil
+		}
+		return actions.Prompt("two-factor authentication code: ")
+	}
+	return storage.Init(config.Client{
+		Address:  ctx.String("address"),
+		CAFile:   ctx.String("ca"),
+		Pin:      ctx.String("pin"),
+		CertFile: ctx.String("cert"),
+		KeyFile:  ctx.String("key"),
+		Insecure: ctx.Bool("insecure"),
+		VaultDir: ctx.String("vault-dir"),
+	})
 }
+
 func main() {
-	store := Init()
+	store := storage.NewMemoryStorage()
 
 	app := cli.NewApp()
 	app.Name = "password keeper"
 	app.Usage = "keeps your passwords"
 	app.Description = "GophKeeper представляет собой клиент-серверную систему, позволяющую пользователю надёжно и безопасно хранить логины, пароли, бинарные данные и прочую приватную информацию."
 	app.Action = actions.MainAction
+	app.Flags = connectionFlags()
+	app.Before = connect
+	// session token lives only while the command runs
+	app.After = func(ctx *cli.Context) error {
+		return store.Logout()
+	}
 
 	app.Commands = []*cli.Command{
 
 		actions.Auth(store),
 		actions.GetData(store),
 		actions.AddData(store),
+		actions.Generate(),
 		actions.Sync(store),
+		actions.List(store),
+		actions.TOTP(store),
+		actions.History(store),
+		actions.Restore(store),
+		actions.Trash(store),
 		actions.DelData(store),
+		actions.TwoFactor(store),
+		actions.Unlock(store),
+		actions.Audit(store),
+		actions.Passwd(store),
 	}
 
 	err := app.Run(os.Args)
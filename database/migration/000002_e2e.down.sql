@@ -0,0 +1,6 @@
+BEGIN ;
+ALTER TABLE keeper DROP COLUMN IF EXISTS key_id;
+ALTER TABLE keeper DROP COLUMN IF EXISTS meta_blob;
+ALTER TABLE keeper DROP COLUMN IF EXISTS data_blob;
+ALTER TABLE users DROP COLUMN IF EXISTS vault_salt;
+COMMIT ;
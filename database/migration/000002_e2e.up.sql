@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS vault_salt bytea;
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS data_blob bytea;
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS meta_blob bytea;
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS key_id varchar(64) NOT NULL DEFAULT '';
+
+COMMIT;
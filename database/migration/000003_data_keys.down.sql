@@ -0,0 +1,5 @@
+BEGIN ;
+DROP TABLE IF EXISTS key_rotation;
+ALTER TABLE keeper DROP COLUMN IF EXISTS data_key_id;
+DROP TABLE IF EXISTS data_keys;
+COMMIT ;
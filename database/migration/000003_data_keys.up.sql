@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS data_keys (
+    id SERIAL PRIMARY KEY,
+    user_id int references users(id) NOT NULL,
+    master_key_id bigint NOT NULL,
+    wrapped_key bytea NOT NULL,
+    created_at timestamp with time zone default CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS data_keys_user_master ON data_keys (user_id, master_key_id);
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS data_key_id int references data_keys(id);
+CREATE TABLE IF NOT EXISTS key_rotation (
+    master_key_id bigint PRIMARY KEY,
+    last_row_id int NOT NULL DEFAULT 0,
+    finished bool NOT NULL DEFAULT false
+    );
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash bytea PRIMARY KEY,
+    user_id int references users(id) NOT NULL,
+    device varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL default CURRENT_TIMESTAMP,
+    expires_at timestamp with time zone NOT NULL
+    );
+CREATE INDEX IF NOT EXISTS sessions_user ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS sessions_expires_at ON sessions (expires_at);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN ;
+ALTER TABLE sessions DROP COLUMN IF EXISTS kind;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp;
+COMMIT ;
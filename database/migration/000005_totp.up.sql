@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS totp (
+    user_id int PRIMARY KEY references users(id),
+    secret bytea NOT NULL,
+    data_key_id int references data_keys(id) NOT NULL,
+    confirmed bool NOT NULL DEFAULT false,
+    last_step bigint NOT NULL DEFAULT 0
+    );
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id SERIAL PRIMARY KEY,
+    user_id int references users(id) NOT NULL,
+    code_hash varchar(64) NOT NULL,
+    used bool NOT NULL DEFAULT false
+    );
+CREATE INDEX IF NOT EXISTS recovery_codes_user ON recovery_codes (user_id);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS kind varchar(16) NOT NULL DEFAULT 'session';
+
+COMMIT;
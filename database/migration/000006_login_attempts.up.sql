@@ -0,0 +1,10 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key varchar(512) PRIMARY KEY,
+    failures int NOT NULL DEFAULT 0,
+    last_failure timestamp with time zone NOT NULL,
+    locked_until timestamp with time zone
+    );
+
+COMMIT;
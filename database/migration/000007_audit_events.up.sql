@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    user_id int references users(id),
+    action varchar(64) NOT NULL,
+    data_id varchar(255) NOT NULL DEFAULT '',
+    peer varchar(64) NOT NULL DEFAULT '',
+    outcome varchar(32) NOT NULL,
+    detail varchar(255) NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL default CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS audit_events_user ON audit_events (user_id, id);
+-- the log is append-only, rows can't be changed or removed
+CREATE OR REPLACE RULE audit_events_no_update AS ON UPDATE TO audit_events DO INSTEAD NOTHING;
+CREATE OR REPLACE RULE audit_events_no_delete AS ON DELETE TO audit_events DO INSTEAD NOTHING;
+
+COMMIT;
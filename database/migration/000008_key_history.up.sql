@@ -0,0 +1,11 @@
+BEGIN;
+
+-- previous vault keys of a user sealed by the client with the current vault key, server can't open them
+CREATE TABLE IF NOT EXISTS vault_key_history (
+    user_id int references users(id) NOT NULL,
+    position int NOT NULL,
+    sealed_key bytea NOT NULL,
+    PRIMARY KEY (user_id, position)
+    );
+
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN ;
+DROP TABLE IF EXISTS blob_chunks;
+DROP TABLE IF EXISTS blobs;
+COMMIT ;
@@ -0,0 +1,23 @@
+BEGIN;
+
+-- blobs are sealed by clients chunk by chunk, server stores them as is
+CREATE TABLE IF NOT EXISTS blobs (
+    user_id int references users(id) NOT NULL,
+    blob_id varchar(64) NOT NULL,
+    size bigint NOT NULL DEFAULT 0,
+    chunks bigint NOT NULL DEFAULT 0,
+    sha256 bytea,
+    complete boolean NOT NULL DEFAULT false,
+    updated_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (user_id, blob_id)
+    );
+CREATE TABLE IF NOT EXISTS blob_chunks (
+    user_id int NOT NULL,
+    blob_id varchar(64) NOT NULL,
+    seq bigint NOT NULL,
+    data bytea NOT NULL,
+    PRIMARY KEY (user_id, blob_id, seq),
+    FOREIGN KEY (user_id, blob_id) REFERENCES blobs(user_id, blob_id) ON DELETE CASCADE
+    );
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS blobs_unfinished_idx;
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS sha256 bytea;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- chunks are checked one by one, so a resumed upload has no hash of the whole blob
+ALTER TABLE blobs DROP COLUMN IF EXISTS sha256;
+-- unfinished uploads are garbage collected by age
+CREATE INDEX IF NOT EXISTS blobs_unfinished_idx ON blobs (updated_at) WHERE NOT complete;
+
+COMMIT;
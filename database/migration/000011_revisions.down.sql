@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS keeper_revision ON keeper;
+DROP FUNCTION IF EXISTS keeper_revision();
+DROP TABLE IF EXISTS keeper_revisions;
+
+COMMIT;
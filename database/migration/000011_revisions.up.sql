@@ -0,0 +1,34 @@
+BEGIN;
+
+-- previous versions of notes, a row is added by the trigger every time a note is overwritten
+CREATE TABLE IF NOT EXISTS keeper_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id int references users(id) NOT NULL,
+    data_id varchar(255) NOT NULL,
+    data_info text NOT NULL,
+    meta_info text,
+    data_blob bytea,
+    meta_blob bytea,
+    key_id varchar(64) NOT NULL DEFAULT '',
+    data_key_id int references data_keys(id),
+    deleted bool NOT NULL DEFAULT false,
+    changed_at timestamp with time zone NOT NULL,
+    revised_at timestamp with time zone NOT NULL default CURRENT_TIMESTAMP
+    );
+CREATE INDEX IF NOT EXISTS keeper_revisions_note ON keeper_revisions (user_id, data_id, id);
+
+-- re-encryption keeps changed_at of the note, so only real changes and deletions are kept
+CREATE OR REPLACE FUNCTION keeper_revision() RETURNS trigger AS $$
+BEGIN
+    IF OLD.changed_at IS DISTINCT FROM NEW.changed_at OR OLD.deleted IS DISTINCT FROM NEW.deleted THEN
+        INSERT INTO keeper_revisions (user_id, data_id, data_info, meta_info, data_blob, meta_blob, key_id, data_key_id, deleted, changed_at)
+        VALUES (OLD.user_id, OLD.data_id, OLD.data_info, OLD.meta_info, OLD.data_blob, OLD.meta_blob, OLD.key_id, OLD.data_key_id, OLD.deleted, COALESCE(OLD.changed_at, now()));
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS keeper_revision ON keeper;
+CREATE TRIGGER keeper_revision BEFORE UPDATE ON keeper FOR EACH ROW EXECUTE FUNCTION keeper_revision();
+
+COMMIT;
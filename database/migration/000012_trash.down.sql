@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS keeper_tombstones;
+DROP TABLE IF EXISTS device_syncs;
+DELETE FROM keeper WHERE purged;
+ALTER TABLE keeper DROP COLUMN IF EXISTS purged;
+
+COMMIT;
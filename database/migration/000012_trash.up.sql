@@ -0,0 +1,15 @@
+BEGIN;
+
+-- purged notes keep an empty tombstone, so devices which still have the note don't bring it back
+ALTER TABLE keeper ADD COLUMN IF NOT EXISTS purged bool NOT NULL DEFAULT false;
+
+-- last synchronization of every device of the user, tombstones are removed once all devices have seen them
+CREATE TABLE IF NOT EXISTS device_syncs (
+    user_id int references users(id) NOT NULL,
+    device varchar(255) NOT NULL,
+    synced_at timestamp with time zone NOT NULL default CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, device)
+    );
+CREATE INDEX IF NOT EXISTS keeper_tombstones ON keeper (changed_at) WHERE deleted;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE key_rotation DROP COLUMN IF EXISTS last_revision_id;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- revisions are re-encrypted by key rotation after notes
+ALTER TABLE key_rotation ADD COLUMN IF NOT EXISTS last_revision_id bigint NOT NULL DEFAULT 0;
+-- rotations finished before revisions were rotated have to run again for them
+UPDATE key_rotation SET finished=false;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS blobs_note_idx;
+ALTER TABLE blobs DROP COLUMN IF EXISTS data_id;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- blobs are linked to notes of uploaded files, so purge erases them with the note
+ALTER TABLE blobs ADD COLUMN IF NOT EXISTS data_id varchar(255) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS blobs_note_idx ON blobs (user_id, data_id);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- fails while longer notes are stored instead of cutting them
+ALTER TABLE keeper ALTER COLUMN meta_info TYPE varchar(255);
+ALTER TABLE keeper ALTER COLUMN data_info TYPE varchar(255);
+
+COMMIT;
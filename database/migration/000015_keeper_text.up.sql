@@ -0,0 +1,7 @@
+BEGIN;
+
+-- sealed notes are base64 envelopes, so 255 characters leave too little room for the note itself
+ALTER TABLE keeper ALTER COLUMN data_info TYPE text;
+ALTER TABLE keeper ALTER COLUMN meta_info TYPE text;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+-- TOTP secrets are re-encrypted by key rotation after notes and revisions
+ALTER TABLE key_rotation ADD COLUMN IF NOT EXISTS last_totp_user int NOT NULL DEFAULT 0;
+-- rotations finished before TOTP secrets were rotated have to run again for them
+UPDATE key_rotation SET finished=false;
+
+COMMIT;
@@ -3,7 +3,9 @@ package actions
 import (
 	"fmt"
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
 	"gophkeeper/internal/storage"
+	"time"
 
 	"github.com/urfave/cli/v2"
 )
@@ -45,7 +47,18 @@ func addData(store storage.Storage) func(ctx *cli.Context) error {
 		if n == 0 {
 			return fmt.Errorf("no argument provided for auth")
 		}
-		if n < 4 {
+		// generated data takes place of the data argument
+		text, metaArg, bits := ctx.Args().Get(3), 4, 0.0
+		if ctx.Bool("generate") {
+			if n != 3 && n != 4 {
+				return fmt.Errorf("wrong amount of arguments")
+			}
+			var err error
+			if text, bits, err = generateSecret(ctx); err != nil {
+				return err
+			}
+			metaArg = 3
+		} else if n < 4 {
 			return fmt.Errorf("not enough arguments provided for auth")
 		}
 		login := ctx.Args().Get(0)
@@ -57,28 +70,44 @@ func addData(store storage.Storage) func(ctx *cli.Context) error {
 		}
 		var data datamodels.Data
 		data.DataID = ctx.Args().Get(2)
-		data.Data = ctx.Args().Get(3)
-		data.Metadata = ctx.Args().Get(4)
+		// with --login the data is the password of a login record, so audit passwords checks it
+		record := records.Text(text)
+		if ctx.IsSet("login") {
+			record = records.LoginPassword(ctx.String("login"), text, "")
+		}
+		data.Data, err = records.Encode(record)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", records.Kind(record), err)
+		}
+		data.Metadata = ctx.Args().Get(metaArg)
 		data.UserID = id
+		if err = setMeta(ctx, &data); err != nil {
+			return err
+		}
 		err = store.AddData(data)
 		if err != nil {
 			return fmt.Errorf("error add happend: %w", err)
 		}
 		fmt.Println("data added successfully")
+		if ctx.Bool("generate") {
+			reportGenerated(ctx, text, bits)
+		}
 		return nil
 	}
 }
 
 // AddData - used to add new data to keep it
-func AddData(store storage.Storage) *cli.Command {
+func AddData(store FileStorage) *cli.Command {
 	return &cli.Command{
-		Name:    "addData",
-		Usage:   "used to add new data to keep it; you need to enter login and password, then data name, data and meta information if needed; example: go run main.go add login password dataID data metaData",
-		Aliases: []string{"add"},
-		Action:  addData(store),
+		Name:        "addData",
+		Usage:       "used to add new data to keep it; you need to enter login and password, then data name, data and meta information if needed; example: go run main.go add login password dataID data metaData; with --generate data is a generated password: go run main.go add --generate --length 24 login password dataID; data is stored as an untyped note, with --login siteLogin it is stored as the password of a login record; typed records are added by subcommands login, card, note and file",
+		Aliases:     []string{"add"},
+		Flags:       append([]cli.Flag{fieldFlag, tagFlag, &cli.StringFlag{Name: "login", Usage: "store data as the password of a login record with this site login"}}, generateFlags...),
+		Action:      addData(store),
+		Subcommands: addSubcommands(store),
 	}
 }
-func getData(store storage.Storage) func(ctx *cli.Context) error {
+func getData(store FileStorage) func(ctx *cli.Context) error {
 	return func(ctx *cli.Context) error {
 		n := ctx.NArg()
 		if n == 0 {
@@ -98,18 +127,37 @@ func getData(store storage.Storage) func(ctx *cli.Context) error {
 		if err != nil {
 			return fmt.Errorf("error get happend: %w", err)
 		}
-		fmt.Println("DataID: " + data.DataID + " Data: " + data.Data + " Meta Info: " + data.Metadata)
+		out := ctx.String("out")
+		if out == "" {
+			fmt.Println(formatData(data, ctx.Bool("reveal")))
+			return nil
+		}
+		r, err := records.Decode(data.Data)
+		if err != nil {
+			return fmt.Errorf("error get happend: %w", err)
+		}
+		if records.Kind(r) != records.KindFile {
+			return fmt.Errorf("--out is supported only for files")
+		}
+		if err = saveFile(store, id, r.GetBinary(), out); err != nil {
+			return fmt.Errorf("error download happend: %w", err)
+		}
+		fmt.Println("file saved to " + out)
 		return nil
 	}
 }
 
 // GetData - used to get data
-func GetData(store storage.Storage) *cli.Command {
+func GetData(store FileStorage) *cli.Command {
 	return &cli.Command{
 		Name:    "get data",
-		Usage:   "used to get data ; you need to enter login and password, then data name; example: go run main.go get login password dataId",
+		Usage:   "used to get data ; you need to enter login and password, then data name; files are saved with --out, card number is shown with --reveal; example: go run main.go get --out key.pem login password dataId",
 		Aliases: []string{"get", "g"},
-		Action:  getData(store),
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "out", Usage: "path to save file of the record"},
+			&cli.BoolFlag{Name: "reveal", Usage: "show full card number and CVV"},
+		},
+		Action: getData(store),
 	}
 }
 func delData(store storage.Storage) func(ctx *cli.Context) error {
@@ -171,7 +219,7 @@ func sync(store storage.Storage) func(ctx *cli.Context) error {
 			return fmt.Errorf("error sync happend: %w", err)
 		}
 		for _, v := range data {
-			fmt.Println("DataID: " + v.DataID + " Data: " + v.Data + " Meta Info: " + v.Metadata)
+			fmt.Println(summary(v))
 		}
 
 		return nil
@@ -188,6 +236,57 @@ func Sync(store storage.Storage) *cli.Command {
 	}
 }
 
+func list(store LocalStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		fields := make(map[string]string)
+		for _, f := range ctx.StringSlice("field") {
+			name, field, err := records.ParseField(f)
+			if err != nil {
+				return fmt.Errorf("invalid field %q: %w", f, err)
+			}
+			fields[name] = field.Value
+		}
+		all, err := store.ListData(id)
+		if err != nil {
+			return fmt.Errorf("error list happend: %w", err)
+		}
+		var data []datamodels.Data
+		for _, v := range all {
+			if records.MatchMeta(v, ctx.StringSlice("tag"), fields) {
+				data = append(data, v)
+			}
+		}
+		now := time.Now()
+		for _, v := range data {
+			fmt.Println(summary(v))
+		}
+		for _, v := range data {
+			if warning := cardWarning(v, now); warning != "" {
+				fmt.Println(warning)
+			}
+		}
+		return nil
+	}
+}
+
+// List - used to list local notes
+func List(store LocalStorage) *cli.Command {
+	return &cli.Command{
+		Name:    "list data",
+		Usage:   "used to list notes kept locally and warn about cards expiring soon; notes are filtered by all given tags and fields; you need to enter login and password; example: go run main.go list --tag prod --field env=staging login password",
+		Aliases: []string{"list", "l"},
+		Flags:   []cli.Flag{tagFlag, fieldFlag},
+		Action:  list(store),
+	}
+}
+
 // MainAction - shows help by default when app started
 func MainAction(ctx *cli.Context) error {
 	ctx.App.Command("help").Run(ctx)
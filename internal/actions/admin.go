@@ -0,0 +1,42 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// AdminStorage - storage with admin functions of the server
+type AdminStorage interface {
+	UnlockAccount(adminToken string, login string, ip string) error
+}
+
+func unlock(store AdminStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 1 && ctx.String("ip") == "" {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		if ctx.String("token") == "" {
+			return fmt.Errorf("admin token required")
+		}
+		err := store.UnlockAccount(ctx.String("token"), ctx.Args().Get(0), ctx.String("ip"))
+		if err != nil {
+			return fmt.Errorf("error unlock happend: %w", err)
+		}
+		fmt.Println("account unlocked")
+		return nil
+	}
+}
+
+// Unlock - used by admin to remove lockout after failed logins
+func Unlock(store AdminStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "unlock",
+		Usage: "used by admin to remove lockout after failed logins; you need to enter login or --ip; example: go run main.go unlock --token adminToken login",
+		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "token", Usage: "admin token of the server", EnvVars: []string{"ADMIN_TOKEN"}},
+			&cli.StringFlag{Name: "ip", Usage: "address to unlock"},
+		},
+		Action: unlock(store),
+	}
+}
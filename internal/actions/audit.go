@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gophkeeper/internal/breach"
+	"gophkeeper/internal/records"
+	pb "gophkeeper/proto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// AuditStorage - storage with access to the server audit log and to local notes checked by audit passwords
+type AuditStorage interface {
+	LocalStorage
+	ListAuditEvents(beforeID int64, limit int32) ([]*pb.AuditEvent, int64, error)
+	// LoginOffline unlocks the local vault without server
+	LoginOffline(login string, password string) (uint32, error)
+}
+
+func auditEvents(store AuditStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		password := ctx.Args().Get(1)
+		if _, err := store.Login(login, password); err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		events, next, err := store.ListAuditEvents(ctx.Int64("before"), int32(ctx.Int("limit")))
+		if err != nil {
+			return fmt.Errorf("error audit happend: %w", err)
+		}
+		for _, e := range events {
+			fmt.Printf("%d %s %s %s %s %s %s\n", e.Id, e.At.AsTime().Local().Format(time.RFC3339), e.Action, e.DataId, e.Outcome, e.Peer, e.Detail)
+		}
+		if next != 0 {
+			fmt.Printf("next page: audit --before %d login password\n", next)
+		}
+		return nil
+	}
+}
+
+// Audit - used to see history of access to the vault
+func Audit(store AuditStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "used to see who accessed your data and when, newest first; you need to enter login and password; example: go run main.go audit --limit 20 login password",
+		Flags: []cli.Flag{
+			&cli.Int64Flag{Name: "before", Usage: "show events older than this id"},
+			&cli.IntFlag{Name: "limit", Value: 50, Usage: "events on a page"},
+		},
+		Action: auditEvents(store),
+		Subcommands: []*cli.Command{
+			{
+				Name:  "passwords",
+				Usage: "checks passwords of login records in the local vault against a Have I Been Pwned SHA-1 file sorted by hash, nothing is sent over network; you need to enter login and password; example: go run main.go audit passwords --hibp pwned-passwords-sha1-ordered-by-hash-v8.txt login password",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "hibp", Required: true, Usage: "path of the HIBP file with HASH:COUNT lines"},
+				},
+				Action: auditPasswords(store),
+			},
+		},
+	}
+}
+
+// breached - login record with password seen in breaches
+type breached struct {
+	dataID string
+	count  int64
+}
+
+func auditPasswords(store AuditStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		dataset, err := breach.Open(ctx.String("hibp"))
+		if err != nil {
+			return fmt.Errorf("error audit happend: %w", err)
+		}
+		defer dataset.Close()
+		// the check is local only, so the master password isn't sent to server either
+		id, err := store.LoginOffline(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		data, err := store.ListData(id)
+		if err != nil {
+			return fmt.Errorf("error audit happend: %w", err)
+		}
+		var found []breached
+		checked := 0
+		for _, v := range data {
+			r, err := records.Decode(v.Data)
+			if err != nil || r.GetLoginPassword().GetPassword() == "" {
+				continue
+			}
+			checked++
+			count, err := dataset.Count(r.GetLoginPassword().GetPassword())
+			if err != nil {
+				return fmt.Errorf("error audit happend: %w", err)
+			}
+			if count > 0 {
+				found = append(found, breached{dataID: v.DataID, count: count})
+			}
+		}
+		sort.Slice(found, func(i, j int) bool {
+			if found[i].count != found[j].count {
+				return found[i].count > found[j].count
+			}
+			return found[i].dataID < found[j].dataID
+		})
+		for _, b := range found {
+			fmt.Printf("%s: password seen %d times in breaches\n", b.dataID, b.count)
+		}
+		fmt.Printf("%d of %d login passwords found in breaches\n", len(found), checked)
+		return nil
+	}
+}
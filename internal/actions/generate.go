@@ -0,0 +1,84 @@
+package actions
+
+import (
+	"fmt"
+
+	"gophkeeper/internal/passgen"
+
+	"github.com/urfave/cli/v2"
+)
+
+// policyFlags - password policy of generate and add --generate
+var policyFlags = []cli.Flag{
+	&cli.IntFlag{Name: "length", Value: passgen.DefaultPolicy().Length, Usage: "password length"},
+	&cli.BoolFlag{Name: "no-lower", Usage: "no lowercase letters"},
+	&cli.BoolFlag{Name: "no-upper", Usage: "no uppercase letters"},
+	&cli.BoolFlag{Name: "no-digits", Usage: "no digits"},
+	&cli.BoolFlag{Name: "no-symbols", Usage: "no symbols"},
+	&cli.BoolFlag{Name: "no-ambiguous", Usage: "leave out characters which are easy to confuse: " + passgen.Ambiguous},
+	&cli.IntFlag{Name: "words", Usage: "generate passphrase of this many words instead of password"},
+	&cli.StringFlag{Name: "separator", Value: "-", Usage: "separator of passphrase words"},
+}
+
+// generateFlags - flags of add commands which generate the secret instead of taking it from arguments
+var generateFlags = append([]cli.Flag{
+	&cli.BoolFlag{Name: "generate", Usage: "generate the password, it is not printed without --show"},
+	&cli.BoolFlag{Name: "show", Usage: "print generated password"},
+}, policyFlags...)
+
+// generateSecret - generates password or passphrase from policy flags and returns it with its entropy
+func generateSecret(ctx *cli.Context) (string, float64, error) {
+	if ctx.IsSet("words") {
+		p := passgen.Passphrase{Words: ctx.Int("words"), Separator: ctx.String("separator")}
+		secret, err := p.Generate()
+		if err != nil {
+			return "", 0, fmt.Errorf("error generate happend: %w", err)
+		}
+		return secret, p.Entropy(), nil
+	}
+	p := passgen.Policy{
+		Length:           ctx.Int("length"),
+		Lower:            !ctx.Bool("no-lower"),
+		Upper:            !ctx.Bool("no-upper"),
+		Digits:           !ctx.Bool("no-digits"),
+		Symbols:          !ctx.Bool("no-symbols"),
+		ExcludeAmbiguous: ctx.Bool("no-ambiguous"),
+	}
+	secret, err := p.Generate()
+	if err != nil {
+		return "", 0, fmt.Errorf("error generate happend: %w", err)
+	}
+	return secret, p.Entropy(), nil
+}
+
+// reportGenerated - prints entropy of the stored secret, the secret itself only with --show
+func reportGenerated(ctx *cli.Context, secret string, bits float64) {
+	fmt.Printf("generated password stored, entropy %.0f bits\n", bits)
+	if ctx.Bool("show") {
+		fmt.Println(secret)
+	}
+}
+
+func generate(ctx *cli.Context) error {
+	if ctx.NArg() != 0 {
+		return fmt.Errorf("wrong amount of arguments")
+	}
+	secret, bits, err := generateSecret(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Println(secret)
+	fmt.Printf("entropy %.0f bits\n", bits)
+	return nil
+}
+
+// Generate - used to generate a password without storing it
+func Generate() *cli.Command {
+	return &cli.Command{
+		Name:    "generate",
+		Usage:   "used to generate a random password or a passphrase with --words and print it with its entropy; use add --generate to store it without printing; example: go run main.go generate --length 24 --no-ambiguous",
+		Aliases: []string{"gen"},
+		Flags:   policyFlags,
+		Action:  generate,
+	}
+}
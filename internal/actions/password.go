@@ -0,0 +1,40 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+)
+
+// PasswordStorage - storage which can change master password of the user
+type PasswordStorage interface {
+	ChangePassword(login string, oldPassword string, newPassword string) error
+}
+
+func passwd(store PasswordStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		oldPassword := ctx.Args().Get(1)
+		newPassword := ctx.Args().Get(2)
+		if newPassword == "" || newPassword == oldPassword {
+			return fmt.Errorf("new password must differ from the old one")
+		}
+		if err := store.ChangePassword(login, oldPassword, newPassword); err != nil {
+			return fmt.Errorf("error password change happend: %w", err)
+		}
+		fmt.Println("password changed, other sessions are logged out")
+		return nil
+	}
+}
+
+// Passwd - used to change master password, all notes are re-encrypted with the new key
+func Passwd(store PasswordStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "passwd",
+		Usage:  "used to change master password; you need to enter login, old password and new password; example: go run main.go passwd login oldPassword newPassword",
+		Action: passwd(store),
+	}
+}
@@ -0,0 +1,287 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/otp"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// metaFlag - meta information of a record
+var metaFlag = &cli.StringFlag{Name: "meta", Usage: "meta information of the record"}
+
+// fieldFlag, tagFlag - structured meta information of a record, they are also filters of list
+var (
+	fieldFlag = &cli.StringSliceFlag{Name: "field", Usage: "typed meta information field name=value, can be repeated"}
+	tagFlag   = &cli.StringSliceFlag{Name: "tag", Usage: "tag of the record, can be repeated"}
+)
+
+// setMeta - sets fields and tags of the note from --field and --tag flags
+func setMeta(ctx *cli.Context, data *datamodels.Data) error {
+	for _, f := range ctx.StringSlice("field") {
+		name, field, err := records.ParseField(f)
+		if err != nil {
+			return fmt.Errorf("invalid field %q: %w", f, err)
+		}
+		if data.Fields == nil {
+			data.Fields = make(map[string]datamodels.Field)
+		}
+		data.Fields[name] = field
+	}
+	tags, err := records.NormalizeTags(ctx.StringSlice("tag"))
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	data.Tags = tags
+	return nil
+}
+
+// FileStorage - storage which uploads and downloads files as blobs
+type FileStorage interface {
+	storage.Storage
+	UploadBlob(userID uint32, dataID string, r io.ReadSeeker) (*pb.Binary, error)
+	ResumeUpload(userID uint32, id, dataID string, r io.ReadSeeker) (*pb.Binary, error)
+	DownloadBlob(userID uint32, ref *pb.Binary, w io.Writer) error
+}
+
+// LocalStorage - storage which reads notes kept locally without server
+type LocalStorage interface {
+	storage.Storage
+	ListData(userID uint32) ([]datamodels.Data, error)
+	LocalData(dataID string, userID uint32) (datamodels.Data, error)
+}
+
+// addRecord - logs in with login and password from the first two arguments and saves the record as dataID
+func addRecord(store storage.Storage, ctx *cli.Context, r *pb.Record) error {
+	if err := records.Validate(r); err != nil {
+		return fmt.Errorf("invalid %s: %w", records.Kind(r), err)
+	}
+	id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+	if err != nil {
+		return fmt.Errorf("error login happend: %w", err)
+	}
+	return saveRecord(store, ctx, id, r)
+}
+
+// saveRecord - saves the record of the logged in user as dataID from the third argument
+func saveRecord(store storage.Storage, ctx *cli.Context, id uint32, r *pb.Record) error {
+	encoded, err := records.Encode(r)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", records.Kind(r), err)
+	}
+	data := datamodels.Data{UserID: id, DataID: ctx.Args().Get(2), Data: encoded, Metadata: ctx.String("meta")}
+	if err = setMeta(ctx, &data); err != nil {
+		return err
+	}
+	if err = store.AddData(data); err != nil {
+		return fmt.Errorf("error add happend: %w", err)
+	}
+	fmt.Println(records.Kind(r) + " added successfully")
+	return nil
+}
+
+func addLogin(store storage.Storage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if !ctx.Bool("generate") {
+			if ctx.NArg() != 5 {
+				return fmt.Errorf("wrong amount of arguments")
+			}
+			return addRecord(store, ctx, records.LoginPassword(ctx.Args().Get(3), ctx.Args().Get(4), ctx.String("url")))
+		}
+		if ctx.NArg() != 4 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		password, bits, err := generateSecret(ctx)
+		if err != nil {
+			return err
+		}
+		if err = addRecord(store, ctx, records.LoginPassword(ctx.Args().Get(3), password, ctx.String("url"))); err != nil {
+			return err
+		}
+		reportGenerated(ctx, password, bits)
+		return nil
+	}
+}
+
+func addCard(store storage.Storage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 5 && ctx.NArg() != 6 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		card := records.Card(ctx.Args().Get(3), ctx.String("holder"), ctx.Args().Get(4), ctx.Args().Get(5), ctx.String("notes"))
+		if err := records.CheckExpiry(card.GetCard(), time.Now()); err != nil {
+			return fmt.Errorf("invalid card: %w", err)
+		}
+		return addRecord(store, ctx, card)
+	}
+}
+
+func addOTP(store storage.Storage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 4 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		key, issuer, account, err := otp.ParseURI(ctx.Args().Get(3))
+		if err != nil {
+			return fmt.Errorf("invalid otp: %w", err)
+		}
+		return addRecord(store, ctx, records.OTP(issuer, account, key))
+	}
+}
+
+func addNote(store storage.Storage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 4 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		return addRecord(store, ctx, records.Text(ctx.Args().Get(3)))
+	}
+}
+
+func addFile(store FileStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 4 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		file, err := os.Open(ctx.Args().Get(3))
+		if err != nil {
+			return fmt.Errorf("error reading file happend: %w", err)
+		}
+		defer file.Close()
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		var blob *pb.Binary
+		if ctx.String("resume") != "" {
+			blob, err = store.ResumeUpload(id, ctx.String("resume"), ctx.Args().Get(2), file)
+		} else {
+			blob, err = store.UploadBlob(id, ctx.Args().Get(2), file)
+		}
+		var interrupted *storage.UploadError
+		if errors.As(err, &interrupted) {
+			return fmt.Errorf("error upload happend: %w; continue it with add file --resume %s", err, interrupted.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("error upload happend: %w", err)
+		}
+		return saveRecord(store, ctx, id, records.Blob(filepath.Base(file.Name()), blob))
+	}
+}
+
+// saveFile - writes file of the record to path, blobs are downloaded to a temporary file which replaces path only after all checks passed
+func saveFile(store FileStorage, id uint32, file *pb.Binary, path string) error {
+	if file.GetBlobId() == "" {
+		return os.WriteFile(path, file.GetContent(), 0600)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err = store.DownloadBlob(id, file, tmp); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// addSubcommands - typed records of the add command
+func addSubcommands(store FileStorage) []*cli.Command {
+	return []*cli.Command{
+		{
+			Name:   records.KindLogin,
+			Usage:  "adds login and password of a site, with --generate the site password is generated and not printed; example: go run main.go add login --url https://example.com login password dataID siteLogin sitePassword",
+			Flags:  append([]cli.Flag{&cli.StringFlag{Name: "url", Usage: "address of the site"}, metaFlag, fieldFlag, tagFlag}, generateFlags...),
+			Action: addLogin(store),
+		},
+		{
+			Name:   records.KindCard,
+			Usage:  "adds bank card, number must pass Luhn check and card must not be expired; example: go run main.go add card --holder \"IVAN IVANOV\" login password dataID 4111111111111111 12/27 123",
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "holder", Usage: "card holder name"}, &cli.StringFlag{Name: "notes", Usage: "billing address and other notes"}, metaFlag, fieldFlag, tagFlag},
+			Action: addCard(store),
+		},
+		{
+			Name:   records.KindNote,
+			Usage:  "adds text note; example: go run main.go add note login password dataID text",
+			Flags:  []cli.Flag{metaFlag, fieldFlag, tagFlag},
+			Action: addNote(store),
+		},
+		{
+			Name:   records.KindOTP,
+			Usage:  "adds TOTP secret from otpauth URI, codes are shown by totp command; example: go run main.go add otp login password dataID \"otpauth://totp/ACME:bot?secret=JBSWY3DPEHPK3PXP\"",
+			Flags:  []cli.Flag{metaFlag, fieldFlag, tagFlag},
+			Action: addOTP(store),
+		},
+		{
+			Name:   records.KindFile,
+			Usage:  "uploads file in encrypted chunks, available only with server connection; example: go run main.go add file login password dataID path",
+			Flags:  []cli.Flag{&cli.StringFlag{Name: "resume", Usage: "id of the interrupted upload of the same file"}, metaFlag, fieldFlag, tagFlag},
+			Action: addFile(store),
+		},
+	}
+}
+
+// formatData - note with its record shown according to the record type, card number is shown only with reveal
+func formatData(data datamodels.Data, reveal bool) string {
+	r, err := records.Decode(data.Data)
+	if err != nil {
+		return "DataID: " + data.DataID + " " + err.Error()
+	}
+	text := "DataID: " + data.DataID + "\n" + records.Format(r, reveal) + "\nMeta Info: " + data.Metadata
+	if meta := records.FormatMeta(data); meta != "" {
+		text += "\nFields: " + meta
+	}
+	if records.Kind(r) == records.KindFile {
+		text += "\nuse --out path to save the file"
+	}
+	return text
+}
+
+// summary - note type without its secret fields
+func summary(data datamodels.Data) string {
+	kind := "unknown"
+	if r, err := records.Decode(data.Data); err == nil {
+		kind = records.Kind(r)
+	}
+	text := "DataID: " + data.DataID + " Type: " + kind + " Meta Info: " + data.Metadata
+	if meta := records.FormatMeta(data); meta != "" {
+		text += " " + meta
+	}
+	return text
+}
+
+// expiryWarning - cards expiring within this time are reported by list
+const expiryWarning = 30 * 24 * time.Hour
+
+// cardWarning - warning about expired card or card expiring soon, empty for other records
+func cardWarning(data datamodels.Data, now time.Time) string {
+	r, err := records.Decode(data.Data)
+	if err != nil || records.Kind(r) != records.KindCard {
+		return ""
+	}
+	expires, err := records.Expires(r.GetCard())
+	if err != nil {
+		return ""
+	}
+	if !now.Before(expires) {
+		return "warning: card " + data.DataID + " expired " + r.GetCard().GetExpiry()
+	}
+	if expires.Sub(now) < expiryWarning {
+		return "warning: card " + data.DataID + " expires soon, " + r.GetCard().GetExpiry()
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package actions
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"github.com/urfave/cli/v2"
+)
+
+// RevisionStorage - storage with previous versions of notes kept by the server
+type RevisionStorage interface {
+	storage.Storage
+	ListRevisions(dataID string) ([]*pb.Revision, error)
+	GetRevision(userID uint32, dataID string, revision int64) (datamodels.Data, error)
+	RestoreRevision(userID uint32, dataID string, revision int64) error
+}
+
+func history(store RevisionStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		dataID := ctx.Args().Get(2)
+		if ctx.IsSet("rev") {
+			data, err := store.GetRevision(id, dataID, ctx.Int64("rev"))
+			if err != nil {
+				return fmt.Errorf("error history happend: %w", err)
+			}
+			fmt.Println(formatData(data, ctx.Bool("reveal")))
+			return nil
+		}
+		revisions, err := store.ListRevisions(dataID)
+		if err != nil {
+			return fmt.Errorf("error history happend: %w", err)
+		}
+		if len(revisions) == 0 {
+			fmt.Println("no previous versions of " + dataID)
+		}
+		for _, r := range revisions {
+			state := "changed"
+			if r.Deleted {
+				state = "deleted"
+			}
+			fmt.Printf("rev %d %s at %s, replaced at %s\n", r.Id, state, r.ChangedAt.AsTime().Local().Format(time.RFC3339), r.RevisedAt.AsTime().Local().Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+// History - used to see previous versions of a note
+func History(store RevisionStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "history",
+		Usage: "used to see previous versions of a note kept by the server, newest first; a version is shown with --rev; you need to enter login and password, then data name; example: go run main.go history --rev 12 login password dataId",
+		Flags: []cli.Flag{
+			&cli.Int64Flag{Name: "rev", Usage: "show this version"},
+			&cli.BoolFlag{Name: "reveal", Usage: "show full card number and CVV"},
+		},
+		Action: history(store),
+	}
+}
+
+func restore(store RevisionStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 4 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		revision, err := strconv.ParseInt(ctx.Args().Get(3), 10, 64)
+		if err != nil {
+			return fmt.Errorf("revision must be a number from history")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		if err = store.RestoreRevision(id, ctx.Args().Get(2), revision); err != nil {
+			return fmt.Errorf("error restore happend: %w", err)
+		}
+		fmt.Println("revision restored, the replaced version is kept in history")
+		return nil
+	}
+}
+
+// Restore - used to make previous version of a note current
+func Restore(store RevisionStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "restore",
+		Usage:  "used to restore previous version of a note, available only with server connection; you need to enter login and password, then data name and revision from history; example: go run main.go restore login password dataId 12",
+		Action: restore(store),
+	}
+}
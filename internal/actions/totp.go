@@ -0,0 +1,50 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/records"
+
+	"github.com/urfave/cli/v2"
+)
+
+func totp(store LocalStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		data, err := store.LocalData(ctx.Args().Get(2), id)
+		if err != nil {
+			return fmt.Errorf("error get happend: %w", err)
+		}
+		r, err := records.Decode(data.Data)
+		if err != nil {
+			return fmt.Errorf("error get happend: %w", err)
+		}
+		if records.Kind(r) != records.KindOTP {
+			return fmt.Errorf("%s is not an otp record", data.DataID)
+		}
+		key := records.OTPKey(r.GetOtp())
+		now := time.Now()
+		code, err := key.At(now)
+		if err != nil {
+			return fmt.Errorf("error code generation happend: %w", err)
+		}
+		fmt.Printf("%s (%ds left)\n", code, key.Remaining(now)/time.Second)
+		return nil
+	}
+}
+
+// TOTP - used to print current code of an otp record, it reads the local vault only
+func TOTP(store LocalStorage) *cli.Command {
+	return &cli.Command{
+		Name:   "totp",
+		Usage:  "used to print current code of an otp record and seconds until it changes, works without server; example: go run main.go totp login password dataID",
+		Action: totp(store),
+	}
+}
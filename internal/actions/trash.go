@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"fmt"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// TrashStorage - storage with deleted notes which can be restored or erased
+type TrashStorage interface {
+	storage.Storage
+	ListDeleted(userID uint32) ([]datamodels.Data, error)
+	Undelete(userID uint32, dataID string) error
+	Purge(userID uint32, dataID string) error
+}
+
+func listTrash(store TrashStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		data, err := store.ListDeleted(id)
+		if err != nil {
+			return fmt.Errorf("error trash happend: %w", err)
+		}
+		if len(data) == 0 {
+			fmt.Println("trash is empty")
+		}
+		for _, v := range data {
+			fmt.Printf("%s, deleted at %s\n", summary(v), v.ChangedAt.Local().Format(time.RFC3339))
+		}
+		return nil
+	}
+}
+
+func restoreTrash(store TrashStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		if err = store.Undelete(id, ctx.Args().Get(2)); err != nil {
+			return fmt.Errorf("error trash happend: %w", err)
+		}
+		fmt.Println("note restored")
+		return nil
+	}
+}
+
+func emptyTrash(store TrashStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 && ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		id, err := store.Login(ctx.Args().Get(0), ctx.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		if err = store.Purge(id, ctx.Args().Get(2)); err != nil {
+			return fmt.Errorf("error trash happend: %w", err)
+		}
+		fmt.Println("erased, other devices drop their copies on next sync")
+		return nil
+	}
+}
+
+// Trash - used to restore or erase deleted notes
+func Trash(store TrashStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "trash",
+		Usage: "used to manage deleted notes, available only with server connection",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "lists deleted notes; you need to enter login and password; example: go run main.go trash list login password",
+				Action: listTrash(store),
+			},
+			{
+				Name:   "restore",
+				Usage:  "brings deleted note back; you need to enter login and password, then data name; example: go run main.go trash restore login password dataId",
+				Action: restoreTrash(store),
+			},
+			{
+				Name:   "empty",
+				Usage:  "erases deleted notes with their previous versions, only the given note is erased when data name is set; you need to enter login and password; example: go run main.go trash empty login password [dataId]",
+				Action: emptyTrash(store),
+			},
+		},
+	}
+}
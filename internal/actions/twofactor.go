@@ -0,0 +1,111 @@
+package actions
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"gophkeeper/internal/otp"
+	"gophkeeper/internal/storage"
+
+	"github.com/urfave/cli/v2"
+)
+
+// issuer - name of the application shown by authenticator apps
+const issuer = "GophKeeper"
+
+// TwoFactorStorage - storage with two-factor authentication management
+type TwoFactorStorage interface {
+	storage.Storage
+	EnrollTOTP() (string, error)
+	ConfirmTOTP(code string) ([]string, error)
+	DisableTOTP(code string) error
+}
+
+// Prompt - prints the prompt and reads a line from standard input
+func Prompt(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func enableTwoFactor(store TwoFactorStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 2 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		password := ctx.Args().Get(1)
+		if _, err := store.Login(login, password); err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		secret, err := store.EnrollTOTP()
+		if err != nil {
+			return fmt.Errorf("error enroll happend: %w", err)
+		}
+		decoded, err := otp.DecodeSecret(secret)
+		if err != nil {
+			return fmt.Errorf("error enroll happend: %w", err)
+		}
+		key := otp.Key{Secret: decoded, Algorithm: "SHA1", Digits: otp.DefaultDigits, Period: otp.DefaultPeriod}
+		fmt.Println("add this key to your authenticator app: " + secret)
+		fmt.Println("or import URI: " + key.URI(issuer, login))
+		code, err := Prompt("enter code from the app: ")
+		if err != nil {
+			return fmt.Errorf("error reading code happend: %w", err)
+		}
+		recovery, err := store.ConfirmTOTP(code)
+		if err != nil {
+			return fmt.Errorf("error confirm happend: %w", err)
+		}
+		fmt.Println("two-factor authentication enabled, keep recovery codes in a safe place, each works once:")
+		for _, v := range recovery {
+			fmt.Println(v)
+		}
+		return nil
+	}
+}
+
+func disableTwoFactor(store TwoFactorStorage) func(ctx *cli.Context) error {
+	return func(ctx *cli.Context) error {
+		if ctx.NArg() != 3 {
+			return fmt.Errorf("wrong amount of arguments")
+		}
+		login := ctx.Args().Get(0)
+		password := ctx.Args().Get(1)
+		code := ctx.Args().Get(2)
+		// every code works once, so the code for disable has to be newer than the one used by login, or a recovery code
+		if _, err := store.Login(login, password); err != nil {
+			return fmt.Errorf("error login happend: %w", err)
+		}
+		if err := store.DisableTOTP(code); err != nil {
+			return fmt.Errorf("error disable happend: %w", err)
+		}
+		fmt.Println("two-factor authentication disabled")
+		return nil
+	}
+}
+
+// TwoFactor - used to enable or disable two-factor authentication
+func TwoFactor(store TwoFactorStorage) *cli.Command {
+	return &cli.Command{
+		Name:  "2fa",
+		Usage: "used to manage two-factor authentication",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "enable",
+				Usage:  "enables TOTP codes for login; you need to enter login and password; example: go run main.go 2fa enable login password",
+				Action: enableTwoFactor(store),
+			},
+			{
+				Name:   "disable",
+				Usage:  "disables TOTP codes; you need to enter login, password and next TOTP code or recovery code; example: go run main.go 2fa disable login password code",
+				Action: disableTwoFactor(store),
+			},
+		},
+	}
+}
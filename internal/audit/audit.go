@@ -0,0 +1,80 @@
+// Package audit keeps append-only history of vault access.
+package audit
+
+import (
+	"sync"
+	"time"
+)
+
+// Paging limits of List
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// Event - single access to the vault
+type Event struct {
+	ID int64
+	// UserID - actor, zero when the user is unknown
+	UserID  uint32
+	Action  string
+	DataID  string
+	Peer    string
+	Outcome string
+	Detail  string
+	Time    time.Time
+}
+
+// Log - append-only storage of events
+type Log interface {
+	// Write appends the event.
+	Write(e Event) error
+	// List returns events of the user newest first with id below beforeID, zero beforeID means from the newest.
+	List(userID uint32, beforeID int64, limit int) ([]Event, error)
+}
+
+// Limit - returns limit within [1, MaxLimit], zero means DefaultLimit
+func Limit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// memoryLog is an implementation of Log which keeps events in memory.
+type memoryLog struct {
+	events []Event
+	mutex  sync.RWMutex
+}
+
+// NewMemory creates Log which keeps events in memory.
+func NewMemory() Log {
+	return &memoryLog{}
+}
+
+// Write appends the event.
+func (ml *memoryLog) Write(e Event) error {
+	ml.mutex.Lock()
+	e.ID = int64(len(ml.events) + 1)
+	ml.events = append(ml.events, e)
+	ml.mutex.Unlock()
+	return nil
+}
+
+// List returns events of the user newest first.
+func (ml *memoryLog) List(userID uint32, beforeID int64, limit int) ([]Event, error) {
+	limit = Limit(limit)
+	ml.mutex.RLock()
+	defer ml.mutex.RUnlock()
+	var events []Event
+	for i := len(ml.events) - 1; i >= 0 && len(events) < limit; i-- {
+		e := ml.events[i]
+		if e.UserID == userID && (beforeID == 0 || e.ID < beforeID) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,54 @@
+// Package audit keeps append-only history of vault access.
+package audit
+
+import (
+	"database/sql"
+)
+
+// dbLog is an implementation of Log backed by the PostgreSQL audit_events table.
+type dbLog struct {
+	db *sql.DB
+}
+
+// NewDB creates Log which writes events to the database.
+func NewDB(db *sql.DB) Log {
+	return &dbLog{db: db}
+}
+
+// Write appends the event, events of unknown users are saved without user id.
+func (dl *dbLog) Write(e Event) error {
+	var userID sql.NullInt64
+	if e.UserID != 0 {
+		userID = sql.NullInt64{Int64: int64(e.UserID), Valid: true}
+	}
+	_, err := dl.db.Exec("insert into audit_events (user_id, action, data_id, peer, outcome, detail, created_at) values ($1, $2, $3, $4, $5, $6, $7);",
+		userID, truncate(e.Action, 64), truncate(e.DataID, 255), truncate(e.Peer, 64), truncate(e.Outcome, 32), truncate(e.Detail, 255), e.Time)
+	return err
+}
+
+// truncate - cuts value to the column size
+func truncate(value string, size int) string {
+	if len(value) > size {
+		return value[:size]
+	}
+	return value
+}
+
+// List returns events of the user newest first.
+func (dl *dbLog) List(userID uint32, beforeID int64, limit int) ([]Event, error) {
+	rows, err := dl.db.Query(`select id, action, data_id, peer, outcome, detail, created_at from audit_events
+where user_id=$1 and ($2 = 0 or id < $2) order by id desc limit $3;`, userID, beforeID, Limit(limit))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		e := Event{UserID: userID}
+		if err = rows.Scan(&e.ID, &e.Action, &e.DataID, &e.Peer, &e.Outcome, &e.Detail, &e.Time); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,177 @@
+// Package blobstore keeps large blobs sealed by clients as sequences of chunks.
+package blobstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Module errors
+var (
+	ErrNotFound = errors.New("blob not found")
+	ErrExists   = errors.New("blob already exists")
+	// ErrOffset - chunk doesn't continue the upload, chunks are accepted strictly in order
+	ErrOffset = errors.New("chunk doesn't continue the upload")
+)
+
+// Blob - information about a stored blob
+type Blob struct {
+	ID string
+	// DataID - note of the uploaded file, the blob is erased when the note is purged
+	DataID string
+	// Size - total size of received chunk data, it is the byte offset of an unfinished upload
+	Size int64
+	// Chunks - amount of received chunks, they are numbered from zero, so it is the number of the next chunk
+	Chunks int64
+	// Complete - upload finished, chunks can't be added anymore
+	Complete  bool
+	UpdatedAt time.Time
+}
+
+// Store - storage of blob chunks
+type Store interface {
+	// Begin starts upload of a new blob of the user which belongs to the note dataID.
+	Begin(userID uint32, blobID, dataID string) error
+	// Append saves chunk of the unfinished upload, seq must be equal to the amount of received chunks.
+	Append(userID uint32, blobID string, seq int64, data []byte) error
+	// Commit marks upload complete.
+	Commit(userID uint32, blobID string) error
+	// Get returns information about the blob.
+	Get(userID uint32, blobID string) (Blob, error)
+	// Chunk returns data of the chunk.
+	Chunk(userID uint32, blobID string, seq int64) ([]byte, error)
+	// Delete removes the blob with its chunks.
+	Delete(userID uint32, blobID string) error
+	// DeleteNote removes blobs of the note and returns their amount.
+	DeleteNote(userID uint32, dataID string) (int64, error)
+	// Expire removes unfinished uploads which got no chunks since before and returns their amount.
+	Expire(before time.Time) (int64, error)
+}
+
+// key - blob of a user
+type key struct {
+	userID uint32
+	blobID string
+}
+
+// memoryBlob - blob with its chunks
+type memoryBlob struct {
+	Blob
+	chunks map[int64][]byte
+}
+
+// memoryStore is an implementation of Store that keeps blobs in memory of a single server.
+type memoryStore struct {
+	blobs map[key]*memoryBlob
+	mutex sync.RWMutex
+}
+
+// NewMemory creates Store which keeps blobs in memory.
+func NewMemory() Store {
+	return &memoryStore{blobs: make(map[key]*memoryBlob)}
+}
+
+// Begin starts upload of a new blob.
+func (ms *memoryStore) Begin(userID uint32, blobID, dataID string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	k := key{userID: userID, blobID: blobID}
+	if _, ok := ms.blobs[k]; ok {
+		return ErrExists
+	}
+	ms.blobs[k] = &memoryBlob{Blob: Blob{ID: blobID, DataID: dataID, UpdatedAt: time.Now()}, chunks: make(map[int64][]byte)}
+	return nil
+}
+
+// Append saves chunk of the upload.
+func (ms *memoryStore) Append(userID uint32, blobID string, seq int64, data []byte) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	b, ok := ms.blobs[key{userID: userID, blobID: blobID}]
+	if !ok || b.Complete {
+		return ErrNotFound
+	}
+	if seq != b.Chunks {
+		return ErrOffset
+	}
+	b.chunks[seq] = append([]byte(nil), data...)
+	b.Size += int64(len(data))
+	b.Chunks++
+	b.UpdatedAt = time.Now()
+	return nil
+}
+
+// Commit marks upload complete.
+func (ms *memoryStore) Commit(userID uint32, blobID string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	b, ok := ms.blobs[key{userID: userID, blobID: blobID}]
+	if !ok {
+		return ErrNotFound
+	}
+	b.Complete, b.UpdatedAt = true, time.Now()
+	return nil
+}
+
+// Get returns information about the blob.
+func (ms *memoryStore) Get(userID uint32, blobID string) (Blob, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	b, ok := ms.blobs[key{userID: userID, blobID: blobID}]
+	if !ok {
+		return Blob{}, ErrNotFound
+	}
+	return b.Blob, nil
+}
+
+// Chunk returns data of the chunk.
+func (ms *memoryStore) Chunk(userID uint32, blobID string, seq int64) ([]byte, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+	b, ok := ms.blobs[key{userID: userID, blobID: blobID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data, ok := b.chunks[seq]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+// Delete removes the blob.
+func (ms *memoryStore) Delete(userID uint32, blobID string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.blobs, key{userID: userID, blobID: blobID})
+	return nil
+}
+
+// DeleteNote removes blobs of the note.
+func (ms *memoryStore) DeleteNote(userID uint32, dataID string) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	var n int64
+	for k, b := range ms.blobs {
+		if k.userID == userID && b.DataID == dataID {
+			delete(ms.blobs, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Expire removes unfinished uploads which got no chunks since before.
+func (ms *memoryStore) Expire(before time.Time) (int64, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	var n int64
+	for k, b := range ms.blobs {
+		if !b.Complete && b.UpdatedAt.Before(before) {
+			delete(ms.blobs, k)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,122 @@
+// Package blobstore keeps large blobs sealed by clients as sequences of chunks.
+package blobstore
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// dbStore is an implementation of Store that keeps chunks in the PostgreSQL blob_chunks table.
+type dbStore struct {
+	db *sql.DB
+}
+
+// NewDB creates Store which keeps blobs in the database.
+func NewDB(db *sql.DB) Store {
+	return &dbStore{db: db}
+}
+
+// Begin starts upload of a new blob.
+func (ds *dbStore) Begin(userID uint32, blobID, dataID string) error {
+	res, err := ds.db.Exec("insert into blobs (user_id, blob_id, data_id, updated_at) values ($1, $2, $3, now()) ON CONFLICT DO NOTHING;", userID, blobID, dataID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrExists
+	}
+	return nil
+}
+
+// Append saves chunk of the upload, the row of the blob is locked, so concurrent uploads of one blob can't skip chunks.
+func (ds *dbStore) Append(userID uint32, blobID string, seq int64, data []byte) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var chunks int64
+	var complete bool
+	err = tx.QueryRow("select chunks, complete from blobs where user_id=$1 and blob_id=$2 for update;", userID, blobID).Scan(&chunks, &complete)
+	if errors.Is(err, sql.ErrNoRows) || complete {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if seq != chunks {
+		return ErrOffset
+	}
+	if _, err = tx.Exec("insert into blob_chunks (user_id, blob_id, seq, data) values ($1, $2, $3, $4);", userID, blobID, seq, data); err != nil {
+		return err
+	}
+	_, err = tx.Exec("update blobs set chunks=chunks+1, size=size+$3, updated_at=now() where user_id=$1 and blob_id=$2;", userID, blobID, len(data))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Commit marks upload complete.
+func (ds *dbStore) Commit(userID uint32, blobID string) error {
+	res, err := ds.db.Exec("update blobs set complete=true, updated_at=now() where user_id=$1 and blob_id=$2;", userID, blobID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Get returns information about the blob.
+func (ds *dbStore) Get(userID uint32, blobID string) (Blob, error) {
+	b := Blob{ID: blobID}
+	err := ds.db.QueryRow("select data_id, size, chunks, complete, updated_at from blobs where user_id=$1 and blob_id=$2;", userID, blobID).
+		Scan(&b.DataID, &b.Size, &b.Chunks, &b.Complete, &b.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Blob{}, ErrNotFound
+	}
+	if err != nil {
+		return Blob{}, err
+	}
+	return b, nil
+}
+
+// Chunk returns data of the chunk.
+func (ds *dbStore) Chunk(userID uint32, blobID string, seq int64) ([]byte, error) {
+	var data []byte
+	err := ds.db.QueryRow("select data from blob_chunks where user_id=$1 and blob_id=$2 and seq=$3;", userID, blobID, seq).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Delete removes the blob, chunks are removed by cascade.
+func (ds *dbStore) Delete(userID uint32, blobID string) error {
+	_, err := ds.db.Exec("delete from blobs where user_id=$1 and blob_id=$2;", userID, blobID)
+	return err
+}
+
+// DeleteNote removes blobs of the note, chunks are removed by cascade.
+func (ds *dbStore) DeleteNote(userID uint32, dataID string) (int64, error) {
+	res, err := ds.db.Exec("delete from blobs where user_id=$1 and data_id=$2;", userID, dataID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Expire removes unfinished uploads which got no chunks since before, chunks are removed by cascade.
+func (ds *dbStore) Expire(before time.Time) (int64, error) {
+	res, err := ds.db.Exec("delete from blobs where not complete and updated_at < $1;", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
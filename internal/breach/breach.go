@@ -0,0 +1,150 @@
+// Package breach looks passwords up in a local Have I Been Pwned dataset without network access.
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxLine - longest line of the dataset, HIBP lines are 40 hex digits, colon and count
+const maxLine = 128
+
+// Module errors
+var (
+	ErrFormat = errors.New("invalid dataset line, expected SHA1:COUNT sorted by hash")
+)
+
+// Dataset - file of uppercase SHA-1 hashes with breach counts, one HASH:COUNT per line sorted by hash,
+// this is the ordered-by-hash SHA-1 download of Have I Been Pwned
+type Dataset struct {
+	r    io.ReaderAt
+	size int64
+	file *os.File
+}
+
+// Open opens the dataset file, it is searched in place without loading.
+func Open(path string) (*Dataset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Dataset{r: f, size: info.Size(), file: f}, nil
+}
+
+// New returns dataset of size bytes read from r.
+func New(r io.ReaderAt, size int64) *Dataset {
+	return &Dataset{r: r, size: size}
+}
+
+// Close closes the dataset file.
+func (d *Dataset) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	return d.file.Close()
+}
+
+// Count returns how many times the password was seen in breaches, 0 if it wasn't.
+func (d *Dataset) Count(password string) (int64, error) {
+	return d.Lookup(sha1.Sum([]byte(password)))
+}
+
+// Lookup returns breach count of the SHA-1 hash by binary search over byte offsets of the file.
+func (d *Dataset) Lookup(hash [sha1.Size]byte) (int64, error) {
+	target := strings.ToUpper(hex.EncodeToString(hash[:]))
+	lo, hi := int64(0), d.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		line, err := d.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == "" {
+			hi = mid
+			continue
+		}
+		h, _, err := parse(line)
+		if err != nil {
+			return 0, err
+		}
+		if h < target {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	line, err := d.lineAt(lo)
+	if err != nil || line == "" {
+		return 0, err
+	}
+	h, count, err := parse(line)
+	if err != nil || h != target {
+		return 0, err
+	}
+	return count, nil
+}
+
+// lineAt - first line starting at offset p or later without line break, empty at the end of the file
+func (d *Dataset) lineAt(p int64) (string, error) {
+	if p > 0 {
+		buf, err := d.read(p - 1)
+		if err != nil {
+			return "", err
+		}
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			if p-1+int64(len(buf)) >= d.size {
+				return "", nil
+			}
+			return "", ErrFormat
+		}
+		p += int64(i)
+	}
+	if p >= d.size {
+		return "", nil
+	}
+	buf, err := d.read(p)
+	if err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i]
+	} else if p+int64(len(buf)) < d.size {
+		return "", ErrFormat
+	}
+	return strings.TrimRight(string(buf), "\r"), nil
+}
+
+// read - bytes of at most two lines from offset
+func (d *Dataset) read(off int64) ([]byte, error) {
+	buf := make([]byte, 2*maxLine)
+	n, err := d.r.ReadAt(buf, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// parse - hash in upper case and count of the line
+func parse(line string) (string, int64, error) {
+	hash, count, ok := strings.Cut(line, ":")
+	if !ok || len(hash) != 2*sha1.Size {
+		return "", 0, ErrFormat
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(count), 10, 64)
+	if err != nil {
+		return "", 0, ErrFormat
+	}
+	return strings.ToUpper(hash), n, nil
+}
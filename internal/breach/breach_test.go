@@ -0,0 +1,65 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dataset - HIBP-format file with the passwords and their counts
+func dataset(t *testing.T, counts map[string]int, crlf bool) string {
+	var lines []string
+	for p, n := range counts {
+		h := sha1.Sum([]byte(p))
+		lines = append(lines, fmt.Sprintf("%s:%d", strings.ToUpper(hex.EncodeToString(h[:])), n))
+	}
+	sort.Strings(lines)
+	sep := "\n"
+	if crlf {
+		sep = "\r\n"
+	}
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, sep)+sep), 0o600))
+	return path
+}
+
+func TestDataset_Count(t *testing.T) {
+	counts := map[string]int{"password": 9545824, "123456": 37359195, "qwerty": 3810555}
+	for i := 0; i < 500; i++ {
+		counts[fmt.Sprintf("filler%d", i)] = i + 1
+	}
+	for _, crlf := range []bool{false, true} {
+		d, err := Open(dataset(t, counts, crlf))
+		require.NoError(t, err)
+		for p, n := range counts {
+			got, err := d.Count(p)
+			require.NoError(t, err)
+			assert.Equal(t, int64(n), got, p)
+		}
+		for _, p := range []string{"correct horse battery staple", "", "filler500"} {
+			got, err := d.Count(p)
+			require.NoError(t, err)
+			assert.Zero(t, got, p)
+		}
+		assert.NoError(t, d.Close())
+	}
+}
+
+func TestDataset_Format(t *testing.T) {
+	d := New(strings.NewReader("not a dataset line\n"), 19)
+	_, err := d.Count("password")
+	assert.ErrorIs(t, err, ErrFormat)
+
+	empty := New(strings.NewReader(""), 0)
+	n, err := empty.Count("password")
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
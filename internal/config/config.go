@@ -0,0 +1,173 @@
+// Package config provides server configuration loaded from flags and environment.
+package config
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Server - configuration of the gRPC server
+type Server struct {
+	// Address - address the server listens on
+	Address string
+	// DatabaseDSN - PostgreSQL connection string
+	DatabaseDSN string
+	// KeyringFile - file with master keys in "id:base64key" lines
+	KeyringFile string
+	// Keyring - master keys passed through environment, used when KeyringFile is empty
+	Keyring string
+	// RotateKeys - re-encrypt notes with the newest master key in background
+	RotateKeys bool
+	// TLSCert and TLSKey - server certificate and key files, required unless Insecure is set
+	TLSCert string
+	TLSKey  string
+	// Insecure - serve plaintext connections without certificate, mirrors --insecure of the client
+	Insecure bool
+	// TLSMinVersion - minimal accepted TLS version, "1.2" or "1.3"
+	TLSMinVersion string
+	// ClientCA - CA file of enrolled client certificates, enables mutual TLS
+	ClientCA string
+	// SessionStore - where session tokens are kept: "memory" or "postgres"
+	SessionStore string
+	// SessionTTL - lifetime of a session token
+	SessionTTL time.Duration
+	// LockoutStore - where failed login attempts are counted: "memory" or "postgres"
+	LockoutStore string
+	// AdminToken - token of admin methods, they are disabled when it is empty
+	AdminToken string
+	// BlobStore - where uploaded files are kept: "postgres" or "memory"
+	BlobStore string
+	// UploadTTL - unfinished uploads which got no chunks for this time are removed
+	UploadTTL time.Duration
+	// RevisionsKeep - how many previous versions of every note are kept, 0 keeps all
+	RevisionsKeep int
+	// RevisionsMaxAge - previous versions older than this are removed, 0 keeps them forever
+	RevisionsMaxAge time.Duration
+	// TrashTTL - deleted notes older than this are removed once all devices of the user have synced them, 0 keeps them forever
+	TrashTTL time.Duration
+}
+
+// Client - configuration of the client connection
+type Client struct {
+	// Address - address of the server
+	Address string
+	// CAFile - CA bundle used instead of system roots
+	CAFile string
+	// Pin - base64 SHA-256 of the server public key
+	Pin string
+	// CertFile and KeyFile - client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// Insecure - connect without TLS
+	Insecure bool
+	// VaultDir - directory of local vault files, working directory when empty
+	VaultDir string
+}
+
+// DefaultServer returns configuration used when nothing is set.
+func DefaultServer() Server {
+	return Server{
+		Address:         ":3200",
+		DatabaseDSN:     "postgresql://localhost:5432/shvm",
+		TLSMinVersion:   "1.2",
+		SessionStore:    "memory",
+		SessionTTL:      time.Hour,
+		LockoutStore:    "memory",
+		BlobStore:       "postgres",
+		UploadTTL:       24 * time.Hour,
+		RevisionsKeep:   20,
+		RevisionsMaxAge: 90 * 24 * time.Hour,
+		TrashTTL:        30 * 24 * time.Hour,
+	}
+}
+
+// DefaultClient returns client configuration used when nothing is set.
+func DefaultClient() Client {
+	return Client{Address: ":3200"}
+}
+
+// LoadServer parses flags and environment, environment has priority over flags.
+func LoadServer() Server {
+	cfg := DefaultServer()
+	flag.StringVar(&cfg.Address, "a", cfg.Address, "server address")
+	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "database connection string")
+	flag.StringVar(&cfg.KeyringFile, "k", cfg.KeyringFile, "file with master keys")
+	flag.BoolVar(&cfg.RotateKeys, "rotate-keys", cfg.RotateKeys, "re-encrypt notes with the newest master key in background")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "server certificate file")
+	flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "server key file")
+	flag.BoolVar(&cfg.Insecure, "insecure", cfg.Insecure, "serve plaintext connections without TLS certificate")
+	flag.StringVar(&cfg.TLSMinVersion, "tls-min-version", cfg.TLSMinVersion, "minimal TLS version: 1.2 or 1.3")
+	flag.StringVar(&cfg.ClientCA, "client-ca", cfg.ClientCA, "CA of client certificates, enables mutual TLS")
+	flag.StringVar(&cfg.SessionStore, "sessions", cfg.SessionStore, "session storage: memory or postgres")
+	flag.DurationVar(&cfg.SessionTTL, "session-ttl", cfg.SessionTTL, "lifetime of a session token")
+	flag.StringVar(&cfg.LockoutStore, "lockout", cfg.LockoutStore, "failed login attempts storage: memory or postgres")
+	flag.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "token of admin methods")
+	flag.StringVar(&cfg.BlobStore, "blobs", cfg.BlobStore, "uploaded files storage: postgres or memory")
+	flag.DurationVar(&cfg.UploadTTL, "upload-ttl", cfg.UploadTTL, "time after which unfinished uploads are removed")
+	flag.IntVar(&cfg.RevisionsKeep, "revisions-keep", cfg.RevisionsKeep, "previous versions kept for every note, 0 keeps all")
+	flag.DurationVar(&cfg.RevisionsMaxAge, "revisions-max-age", cfg.RevisionsMaxAge, "age after which previous versions are removed, 0 keeps them forever")
+	flag.DurationVar(&cfg.TrashTTL, "trash-ttl", cfg.TrashTTL, "age after which deleted notes synced by all devices are removed, 0 keeps them forever")
+	flag.Parse()
+
+	lookupString("ADDRESS", &cfg.Address)
+	lookupString("DATABASE_DSN", &cfg.DatabaseDSN)
+	lookupString("KEYRING_FILE", &cfg.KeyringFile)
+	lookupString("KEYRING", &cfg.Keyring)
+	lookupBool("ROTATE_KEYS", &cfg.RotateKeys)
+	lookupString("TLS_CERT", &cfg.TLSCert)
+	lookupString("TLS_KEY", &cfg.TLSKey)
+	lookupBool("INSECURE", &cfg.Insecure)
+	lookupString("TLS_MIN_VERSION", &cfg.TLSMinVersion)
+	lookupString("CLIENT_CA", &cfg.ClientCA)
+	lookupString("SESSION_STORE", &cfg.SessionStore)
+	lookupDuration("SESSION_TTL", &cfg.SessionTTL)
+	lookupString("LOCKOUT_STORE", &cfg.LockoutStore)
+	lookupString("ADMIN_TOKEN", &cfg.AdminToken)
+	lookupString("BLOB_STORE", &cfg.BlobStore)
+	lookupDuration("UPLOAD_TTL", &cfg.UploadTTL)
+	lookupInt("REVISIONS_KEEP", &cfg.RevisionsKeep)
+	lookupDuration("REVISIONS_MAX_AGE", &cfg.RevisionsMaxAge)
+	lookupDuration("TRASH_TTL", &cfg.TrashTTL)
+	return cfg
+}
+
+// lookupString - overrides value with environment variable if it is set
+func lookupString(name string, value *string) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v
+	}
+}
+
+// lookupBool - overrides value with environment variable if it is set
+func lookupBool(name string, value *bool) {
+	if v, ok := os.LookupEnv(name); ok {
+		*value = v == "true" || v == "1"
+	}
+}
+
+// lookupInt - overrides value with environment variable if it is set and valid
+func lookupInt(name string, value *int) {
+	if v, ok := os.LookupEnv(name); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			log.Printf("invalid %s: %v", name, err)
+			return
+		}
+		*value = n
+	}
+}
+
+// lookupDuration - overrides value with environment variable if it is set and valid
+func lookupDuration(name string, value *time.Duration) {
+	if v, ok := os.LookupEnv(name); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			log.Printf("invalid %s: %v", name, err)
+			return
+		}
+		*value = d
+	}
+}
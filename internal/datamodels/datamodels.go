@@ -8,12 +8,19 @@ type Auth struct {
 	ID       uint32 `json:"ID"`
 	Login    string `json:"Login"`
 	Password string `json:"Password"`
+	// Salt - base64 salt for deriving vault key from the master password
+	Salt string `json:"Salt,omitempty"`
+	// KeyHistory - base64 vault keys of previous passwords sealed with the current vault key
+	KeyHistory []string `json:"KeyHistory,omitempty"`
 }
 
 // Login - struct for login
 type Login struct {
 	ID       uint32 `json:"ID"`
 	Password string `json:"Password"`
+	Salt     string `json:"Salt,omitempty"`
+	// KeyHistory - base64 vault keys of previous passwords sealed with the current vault key
+	KeyHistory []string `json:"KeyHistory,omitempty"`
 }
 
 // Data - struct for all information about 1 note
@@ -24,6 +31,39 @@ type Data struct {
 	Metadata  string    `json:"Metadata"`
 	ChangedAt time.Time `json:"ChangedAt"`
 	Deleted   bool      `json:"Deleted"`
+	// DataBlob, MetaBlob - envelopes sealed by the client, server stores them as is when KeyID is set
+	DataBlob []byte `json:"DataBlob,omitempty"`
+	MetaBlob []byte `json:"MetaBlob,omitempty"`
+	KeyID    string `json:"KeyID,omitempty"`
+	// Fields, Tags - structured meta information, client seals them together with Metadata
+	Fields map[string]Field `json:"Fields,omitempty"`
+	Tags   []string         `json:"Tags,omitempty"`
+	// Purged - note is erased from the trash, only its tombstone is left
+	Purged bool `json:"Purged,omitempty"`
+}
+
+// Types of meta information fields
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldBool   = "bool"
+	FieldTime   = "time"
+	FieldURL    = "url"
+)
+
+// Field - typed value of a meta information field, Value is kept in canonical form of the type
+type Field struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Revision - previous version of a note
+type Revision struct {
+	ID        int64
+	ChangedAt time.Time
+	// RevisedAt - when the version was replaced
+	RevisedAt time.Time
+	Deleted   bool
 }
 
 // UniqueData - unique constraint from database for in memory storage
@@ -0,0 +1,136 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"gophkeeper/internal/audit"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// auditedMethods - methods written to the audit log with their action names
+var auditedMethods = map[string]string{
+	pb.Gophkeeper_Login_FullMethodName:              "Login",
+	pb.Gophkeeper_Auth_FullMethodName:               "Auth",
+	pb.Gophkeeper_VerifySecondFactor_FullMethodName: "VerifySecondFactor",
+	pb.Gophkeeper_AddData_FullMethodName:            "AddData",
+	pb.Gophkeeper_GetData_FullMethodName:            "GetData",
+	pb.Gophkeeper_DelData_FullMethodName:            "DelData",
+	pb.Gophkeeper_Sync_FullMethodName:               "Sync",
+	pb.Gophkeeper_ClientSync_FullMethodName:         "ClientSync",
+	pb.Gophkeeper_ChangePassword_FullMethodName:     "ChangePassword",
+	pb.Gophkeeper_ListRevisions_FullMethodName:      "ListRevisions",
+	pb.Gophkeeper_GetRevision_FullMethodName:        "GetRevision",
+	pb.Gophkeeper_RestoreRevision_FullMethodName:    "RestoreRevision",
+	pb.Gophkeeper_ListDeleted_FullMethodName:        "ListDeleted",
+	pb.Gophkeeper_Undelete_FullMethodName:           "Undelete",
+	pb.Gophkeeper_Purge_FullMethodName:              "Purge",
+	pb.Gophkeeper_DeleteBlob_FullMethodName:         "DeleteBlob",
+}
+
+// UnaryAudit - unary server interceptor which writes audited methods to the audit log.
+// It runs before UnaryAuth, so calls rejected for a missing, expired or revoked token are logged too,
+// the caller of accepted calls is filled in by UnaryAuth.
+func (g *GophKeeperServer) UnaryAudit(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	action, ok := auditedMethods[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+	caller, _ := PrincipalFromContext(ctx)
+	resp, err := handler(context.WithValue(ctx, auditCallerKey{}, &caller), req)
+	event := audit.Event{Action: action, UserID: caller.UserID, Peer: peerIP(ctx), Outcome: status.Code(err).String(), Time: time.Now()}
+	switch r := req.(type) {
+	case *pb.AddDataRequest:
+		event.DataID = r.GetData().GetDataId()
+	case *pb.GetDataRequest:
+		event.DataID = r.DataId
+	case *pb.RevisionRequest:
+		event.DataID = r.DataId
+		event.Detail = fmt.Sprintf("revision %d", r.Revision)
+	case *pb.PurgeRequest:
+		event.DataID = r.DataId
+		if r.All {
+			event.Detail = "all"
+		}
+	case *pb.BlobRequest:
+		event.Detail = "blob " + r.BlobId
+	case *pb.ClientSyncRequest:
+		event.Detail = fmt.Sprintf("%d notes", len(r.Data))
+	}
+	switch r := resp.(type) {
+	case *pb.AuthLoginResponse:
+		event.UserID = r.GetId()
+		if r.GetChallenge() != "" {
+			event.Detail = "second factor required"
+		}
+	case *pb.SynchronizationResponse:
+		if r != nil {
+			event.Detail = fmt.Sprintf("%d notes", len(r.Data))
+		}
+	}
+	if login, ok := req.(*pb.AuthLoginRequest); ok && event.UserID == 0 {
+		// failed attempts are recorded for the attacked user
+		event.UserID = g.loginActor(login.Login)
+	}
+	if errWrite := g.audit.Write(event); errWrite != nil {
+		log.Printf("audit write failed: %v", errWrite)
+	}
+	return resp, err
+}
+
+// auditStream - writes call of a streaming method to the audit log, UnaryAudit sees only unary calls
+func (g *GophKeeperServer) auditStream(ctx context.Context, action string, dataID string, err error) {
+	event := audit.Event{Action: action, DataID: dataID, Peer: peerIP(ctx), Outcome: status.Code(err).String(), Time: time.Now()}
+	if p, ok := PrincipalFromContext(ctx); ok {
+		event.UserID = p.UserID
+	}
+	if errWrite := g.audit.Write(event); errWrite != nil {
+		log.Printf("audit write failed: %v", errWrite)
+	}
+}
+
+// loginActor - resolves user of the login, zero for unknown logins
+func (g *GophKeeperServer) loginActor(login string) uint32 {
+	id, err := g.db.UserID(login)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// ListAuditEvents returns audit events of the caller, newest first.
+func (g *GophKeeperServer) ListAuditEvents(ctx context.Context, in *pb.ListAuditEventsRequest) (*pb.ListAuditEventsResponse, error) {
+	var resp pb.ListAuditEventsResponse
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := audit.Limit(int(in.Limit))
+	events, err := g.audit.List(p.UserID, in.BeforeId, limit)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &pb.AuditEvent{
+			Id:      e.ID,
+			Action:  e.Action,
+			DataId:  e.DataID,
+			Peer:    e.Peer,
+			Outcome: e.Outcome,
+			Detail:  e.Detail,
+			At:      timestamppb.New(e.Time),
+		})
+	}
+	if len(events) == limit {
+		resp.NextBeforeId = events[len(events)-1].ID
+	}
+	return &resp, nil
+}
@@ -0,0 +1,87 @@
+package grpcfuncs
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"gophkeeper/internal/audit"
+	"gophkeeper/internal/sessionstorage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryAudit(t *testing.T) {
+	g := GophKeeperServer{audit: audit.NewMemory()}
+	ctx := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 3, Token: "token"})
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 4000}})
+	get := &grpc.UnaryServerInfo{FullMethod: pb.Gophkeeper_GetData_FullMethodName}
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return &pb.GetDataResponse{}, nil }
+	notFound := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	for i := 0; i < 3; i++ {
+		_, err := g.UnaryAudit(ctx, &pb.GetDataRequest{DataId: "card"}, get, ok)
+		require.NoError(t, err)
+	}
+	_, err := g.UnaryAudit(ctx, &pb.GetDataRequest{DataId: "missing"}, get, notFound)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	// methods which are not audited are skipped
+	_, err = g.UnaryAudit(ctx, nil, &grpc.UnaryServerInfo{FullMethod: pb.Gophkeeper_Refresh_FullMethodName}, ok)
+	require.NoError(t, err)
+
+	page, err := g.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{Limit: 3})
+	require.NoError(t, err)
+	require.Len(t, page.Events, 3)
+	assert.Equal(t, "GetData", page.Events[0].Action)
+	assert.Equal(t, "missing", page.Events[0].DataId)
+	assert.Equal(t, "NotFound", page.Events[0].Outcome)
+	assert.Equal(t, "10.0.0.2", page.Events[0].Peer)
+	assert.Equal(t, "OK", page.Events[1].Outcome)
+
+	page, err = g.ListAuditEvents(ctx, &pb.ListAuditEventsRequest{BeforeId: page.NextBeforeId, Limit: 3})
+	require.NoError(t, err)
+	assert.Len(t, page.Events, 1)
+	assert.Zero(t, page.NextBeforeId)
+
+	other := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 4})
+	page, err = g.ListAuditEvents(other, &pb.ListAuditEventsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Events)
+}
+
+func TestUnaryAudit_BeforeAuth(t *testing.T) {
+	g := GophKeeperServer{audit: audit.NewMemory(), users: sessionstorage.NewAuthUsersStorage()}
+	_, err := g.users.AddUser("token", 5, "laptop")
+	require.NoError(t, err)
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Gophkeeper_GetData_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return &pb.GetDataResponse{}, nil }
+	call := func(token string) error {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("userid", token))
+		_, err := g.UnaryAudit(ctx, &pb.GetDataRequest{DataId: "card"}, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return g.UnaryAuth(ctx, req, info, handler)
+		})
+		return err
+	}
+	require.NoError(t, call("token"))
+	require.NoError(t, g.users.DelUser("token"))
+	assert.Equal(t, codes.Unauthenticated, status.Code(call("token")))
+
+	events, err := g.audit.List(5, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "OK", events[0].Outcome)
+	// revoked token has no user, the attempt is still logged
+	events, err = g.audit.List(0, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, "Unauthenticated", events[0].Outcome)
+	assert.Equal(t, "card", events[0].DataID)
+}
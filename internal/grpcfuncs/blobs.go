@@ -0,0 +1,209 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"log"
+	"regexp"
+	"time"
+
+	"gophkeeper/internal/blobstore"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// maxChunkSize - largest sealed chunk accepted by UploadBlob
+	maxChunkSize = 128 << 10
+	// maxBlobSize - largest uploaded blob
+	maxBlobSize = 1 << 30
+	// maxDataIDLen - longest data id of the note a blob is linked to
+	maxDataIDLen = 255
+	// uploadCleanupInterval - how often unfinished uploads are garbage collected
+	uploadCleanupInterval = 10 * time.Minute
+)
+
+// blobID - ids of blobs are chosen by clients
+var blobID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// mapBlobErr - maps err from blob store to grpc error codes
+func mapBlobErr(err error) error {
+	switch err {
+	case blobstore.ErrNotFound:
+		return status.Error(codes.NotFound, "blob not found")
+	case blobstore.ErrExists:
+		return status.Error(codes.AlreadyExists, "blob already exists")
+	case blobstore.ErrOffset:
+		return status.Error(codes.FailedPrecondition, "chunk doesn't continue the upload, check UploadStatus")
+	}
+	log.Printf("blob store failed: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// UploadBlob receives blob sealed by the client chunk by chunk, the first chunk carries blob id and data id of the note,
+// so the blob is erased when the note is purged. Upload of an unfinished blob continues from the chunk after the last
+// received one, received chunks are kept when the stream breaks, so the client asks UploadStatus and resumes.
+// The blob is complete only after the last message with amount of chunks and size of the whole blob which match received ones.
+func (g *GophKeeperServer) UploadBlob(stream pb.Gophkeeper_UploadBlobServer) (err error) {
+	ctx := stream.Context()
+	p, err := principal(ctx)
+	if err != nil {
+		return err
+	}
+	chunk, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty upload")
+	}
+	if err != nil {
+		return err
+	}
+	id := chunk.BlobId
+	defer func() { g.auditStream(ctx, "UploadBlob", id, err) }()
+	if !blobID.MatchString(id) {
+		return status.Error(codes.InvalidArgument, "invalid blob id")
+	}
+	if len(chunk.DataId) > maxDataIDLen {
+		return status.Error(codes.InvalidArgument, "data id is too long")
+	}
+	blob, err := g.blobs.Get(p.UserID, id)
+	if err == blobstore.ErrNotFound {
+		err = g.blobs.Begin(p.UserID, id, chunk.DataId)
+	} else if err == nil && blob.Complete {
+		err = blobstore.ErrExists
+	}
+	if err != nil {
+		return mapBlobErr(err)
+	}
+	size, err := g.receiveChunks(stream, p.UserID, id, blob, chunk)
+	if err != nil {
+		return err
+	}
+	if err = g.blobs.Commit(p.UserID, id); err != nil {
+		return mapBlobErr(err)
+	}
+	return stream.SendAndClose(&pb.UploadBlobResponse{BlobId: id, Size: size})
+}
+
+// receiveChunks - checks and saves chunks starting from the first one until the last message, returns size of the blob.
+// Stream closed without the last message leaves the upload unfinished, so a client which failed in the middle can't complete it.
+func (g *GophKeeperServer) receiveChunks(stream pb.Gophkeeper_UploadBlobServer, userID uint32, id string, blob blobstore.Blob, chunk *pb.BlobChunk) (int64, error) {
+	size, chunks := blob.Size, blob.Chunks
+	for !chunk.Last {
+		if len(chunk.Data) > maxChunkSize {
+			return 0, status.Error(codes.InvalidArgument, "chunk is too large")
+		}
+		if len(chunk.Data) > 0 {
+			size += int64(len(chunk.Data))
+			if size > maxBlobSize {
+				return 0, status.Error(codes.InvalidArgument, "blob is too large")
+			}
+			sum := sha256.Sum256(chunk.Data)
+			if !bytes.Equal(chunk.Sha256, sum[:]) {
+				return 0, status.Errorf(codes.DataLoss, "chunk %d hash mismatch", chunk.Seq)
+			}
+			if err := g.blobs.Append(userID, id, chunk.Seq, chunk.Data); err != nil {
+				return 0, mapBlobErr(err)
+			}
+			chunks++
+		}
+		var err error
+		chunk, err = stream.Recv()
+		if err == io.EOF {
+			return 0, status.Error(codes.Aborted, "upload wasn't finished, check UploadStatus and resume")
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(chunk.Data) > 0 {
+		return 0, status.Error(codes.InvalidArgument, "last message of upload carries data")
+	}
+	if chunk.Chunks != chunks || chunk.Size != size {
+		return 0, status.Errorf(codes.InvalidArgument, "upload has %d chunks of %d bytes, not %d of %d", chunks, size, chunk.Chunks, chunk.Size)
+	}
+	return size, nil
+}
+
+// UploadStatus returns how much of the upload the server has received.
+func (g *GophKeeperServer) UploadStatus(ctx context.Context, in *pb.BlobRequest) (*pb.UploadStatusResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := g.blobs.Get(p.UserID, in.BlobId)
+	if err != nil {
+		return nil, mapBlobErr(err)
+	}
+	return &pb.UploadStatusResponse{BlobId: blob.ID, Chunks: blob.Chunks, Size: blob.Size, Complete: blob.Complete}, nil
+}
+
+// DeleteBlob erases blob of the caller replaced by another file in the record of its note.
+func (g *GophKeeperServer) DeleteBlob(ctx context.Context, in *pb.BlobRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = g.blobs.Get(p.UserID, in.BlobId); err != nil {
+		return nil, mapBlobErr(err)
+	}
+	if err = g.blobs.Delete(p.UserID, in.BlobId); err != nil {
+		return nil, mapBlobErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// cleanupUploads - periodically removes unfinished uploads which got no chunks during ttl
+func (g *GophKeeperServer) cleanupUploads(ttl time.Duration) {
+	for range time.Tick(uploadCleanupInterval) {
+		n, err := g.blobs.Expire(time.Now().Add(-ttl))
+		if err != nil {
+			log.Printf("upload cleanup failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("removed %d unfinished uploads", n)
+		}
+	}
+}
+
+// DownloadBlob sends uploaded blob chunk by chunk, the first chunk carries size of all chunk data.
+func (g *GophKeeperServer) DownloadBlob(in *pb.BlobRequest, stream pb.Gophkeeper_DownloadBlobServer) (err error) {
+	ctx := stream.Context()
+	p, err := principal(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { g.auditStream(ctx, "DownloadBlob", in.BlobId, err) }()
+	blob, err := g.blobs.Get(p.UserID, in.BlobId)
+	if err == nil && !blob.Complete {
+		err = blobstore.ErrNotFound
+	}
+	if err != nil {
+		return mapBlobErr(err)
+	}
+	head := &pb.BlobChunk{BlobId: blob.ID, Size: blob.Size}
+	if blob.Chunks == 0 {
+		return stream.Send(head)
+	}
+	for seq := int64(0); seq < blob.Chunks; seq++ {
+		data, err := g.blobs.Chunk(p.UserID, blob.ID, seq)
+		if err != nil {
+			return mapBlobErr(err)
+		}
+		msg := &pb.BlobChunk{Data: data, Seq: seq}
+		if seq == 0 {
+			head.Data = data
+			msg = head
+		}
+		if err = stream.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package grpcfuncs
+
+import (
+	"context"
+	"crypto/sha256"
+	"io"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/audit"
+	"gophkeeper/internal/blobstore"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// uploadStream - client side of UploadBlob replayed from a list of chunks, err is returned instead of io.EOF when it is set
+type uploadStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks []*pb.BlobChunk
+	err    error
+	resp   *pb.UploadBlobResponse
+}
+
+func (s *uploadStream) Context() context.Context { return s.ctx }
+
+func (s *uploadStream) Recv() (*pb.BlobChunk, error) {
+	if len(s.chunks) == 0 {
+		if s.err != nil {
+			return nil, s.err
+		}
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *uploadStream) SendAndClose(resp *pb.UploadBlobResponse) error {
+	s.resp = resp
+	return nil
+}
+
+// downloadStream - collects chunks sent by DownloadBlob
+type downloadStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	chunks []*pb.BlobChunk
+}
+
+func (s *downloadStream) Context() context.Context { return s.ctx }
+
+func (s *downloadStream) Send(chunk *pb.BlobChunk) error {
+	s.chunks = append(s.chunks, chunk)
+	return nil
+}
+
+// chunk - data chunk of upload with its hash
+func chunk(seq int64, data string) *pb.BlobChunk {
+	sum := sha256.Sum256([]byte(data))
+	return &pb.BlobChunk{Seq: seq, Data: []byte(data), Sha256: sum[:]}
+}
+
+// last - message which completes upload of chunks with size of data
+func last(chunks, size int64) *pb.BlobChunk {
+	return &pb.BlobChunk{Last: true, Chunks: chunks, Size: size}
+}
+
+func TestBlobs(t *testing.T) {
+	g := GophKeeperServer{blobs: blobstore.NewMemory(), audit: audit.NewMemory()}
+	ctx := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 3, Token: "token"})
+
+	// stream breaks after the first chunk, it is kept for resume
+	up := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, chunk(0, "first")}, err: status.Error(codes.Unavailable, "broken")}
+	assert.Equal(t, codes.Unavailable, status.Code(g.UploadBlob(up)))
+	st, err := g.UploadStatus(ctx, &pb.BlobRequest{BlobId: "blob"})
+	require.NoError(t, err)
+	assert.Equal(t, &pb.UploadStatusResponse{BlobId: "blob", Chunks: 1, Size: 5}, st)
+
+	// chunks which don't continue the upload are rejected
+	skip := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, chunk(2, "third")}}
+	assert.Equal(t, codes.FailedPrecondition, status.Code(g.UploadBlob(skip)))
+	corrupted := chunk(1, "second")
+	corrupted.Data = []byte("changed")
+	bad := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, corrupted}}
+	assert.Equal(t, codes.DataLoss, status.Code(g.UploadBlob(bad)))
+
+	// stream closed without the last message, e.g. by a client which failed to read the file, doesn't complete the blob
+	closed := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, chunk(1, "second")}}
+	assert.Equal(t, codes.Aborted, status.Code(g.UploadBlob(closed)))
+	st, err = g.UploadStatus(ctx, &pb.BlobRequest{BlobId: "blob"})
+	require.NoError(t, err)
+	assert.Equal(t, &pb.UploadStatusResponse{BlobId: "blob", Chunks: 2, Size: 11}, st)
+	// the last message has to match received chunks
+	short := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, last(3, 17)}}
+	assert.Equal(t, codes.InvalidArgument, status.Code(g.UploadBlob(short)))
+
+	up = &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, last(2, 11)}}
+	require.NoError(t, g.UploadBlob(up))
+	assert.Equal(t, int64(11), up.resp.Size)
+	st, err = g.UploadStatus(ctx, &pb.BlobRequest{BlobId: "blob"})
+	require.NoError(t, err)
+	assert.True(t, st.Complete)
+
+	down := &downloadStream{ctx: ctx}
+	require.NoError(t, g.DownloadBlob(&pb.BlobRequest{BlobId: "blob"}, down))
+	require.Len(t, down.chunks, 2)
+	assert.Equal(t, int64(11), down.chunks[0].Size)
+	assert.Equal(t, "first", string(down.chunks[0].Data))
+	assert.Equal(t, "second", string(down.chunks[1].Data))
+
+	// another user can't read the blob
+	other := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 4, Token: "other"})
+	assert.Equal(t, codes.NotFound, status.Code(g.DownloadBlob(&pb.BlobRequest{BlobId: "blob"}, &downloadStream{ctx: other})))
+
+	dup := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "blob"}, chunk(0, "first"), last(1, 5)}}
+	assert.Equal(t, codes.AlreadyExists, status.Code(g.UploadBlob(dup)))
+
+	// unfinished uploads are garbage collected, complete blobs stay
+	stale := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "stale"}, chunk(0, "first")}, err: status.Error(codes.Canceled, "canceled")}
+	assert.Equal(t, codes.Canceled, status.Code(g.UploadBlob(stale)))
+	n, err := g.blobs.Expire(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = g.blobs.Get(3, "stale")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, err = g.blobs.Get(3, "blob")
+	assert.NoError(t, err)
+
+	events, err := g.audit.List(3, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 9)
+}
+
+func TestDeleteNoteBlobs(t *testing.T) {
+	g := GophKeeperServer{blobs: blobstore.NewMemory(), audit: audit.NewMemory()}
+	ctx := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 3, Token: "token"})
+	for blob, note := range map[string]string{"purged": "file", "kept": "other", "unlinked": ""} {
+		up := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: blob, DataId: note}, chunk(0, "data"), last(1, 4)}}
+		require.NoError(t, g.UploadBlob(up))
+	}
+	long := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "long", DataId: string(make([]byte, maxDataIDLen+1))}}}
+	assert.Equal(t, codes.InvalidArgument, status.Code(g.UploadBlob(long)))
+
+	// blobs of the purged note are erased, blobs without note are never matched
+	require.NoError(t, g.deleteNoteBlobs(3, "file"))
+	require.NoError(t, g.deleteNoteBlobs(3, ""))
+	require.NoError(t, g.deleteNoteBlobs(4, "other"))
+	_, err := g.blobs.Get(3, "purged")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	for _, blob := range []string{"kept", "unlinked"} {
+		_, err = g.blobs.Get(3, blob)
+		assert.NoError(t, err, blob)
+	}
+}
+
+func TestDeleteBlob(t *testing.T) {
+	g := GophKeeperServer{blobs: blobstore.NewMemory(), audit: audit.NewMemory()}
+	ctx := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 3, Token: "token"})
+	up := &uploadStream{ctx: ctx, chunks: []*pb.BlobChunk{{BlobId: "old", DataId: "file"}, chunk(0, "data"), last(1, 4)}}
+	require.NoError(t, g.UploadBlob(up))
+
+	// another user can't erase the blob
+	other := context.WithValue(context.Background(), principalKey{}, Principal{UserID: 4, Token: "other"})
+	_, err := g.DeleteBlob(other, &pb.BlobRequest{BlobId: "old"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = g.DeleteBlob(ctx, &pb.BlobRequest{BlobId: "old"})
+	require.NoError(t, err)
+	_, err = g.blobs.Get(3, "old")
+	assert.Equal(t, blobstore.ErrNotFound, err)
+	_, err = g.DeleteBlob(ctx, &pb.BlobRequest{BlobId: "old"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
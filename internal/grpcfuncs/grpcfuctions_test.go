@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/grpcfuncs"
 	pb "gophkeeper/proto"
 
@@ -17,7 +18,7 @@ import (
 
 func TestAuth(t *testing.T) {
 	// Start the gRPC server in a separate goroutine
-	g := grpcfuncs.NewGophKeeperServer()
+	g := grpcfuncs.NewGophKeeperServer(config.DefaultServer())
 	go func() {
 
 		listen, err := net.Listen("tcp", ":3200")
@@ -25,7 +26,7 @@ func TestAuth(t *testing.T) {
 			log.Fatal(err)
 		}
 
-		s := grpc.NewServer()
+		s := grpc.NewServer(grpc.ChainUnaryInterceptor(g.UnaryAuth), grpc.ChainStreamInterceptor(g.StreamAuth))
 		pb.RegisterGophkeeperServer(s, &g)
 
 		if err := s.Serve(listen); err != nil {
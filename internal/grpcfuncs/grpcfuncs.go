@@ -6,7 +6,12 @@ import (
 	"log"
 	"time"
 
+	"gophkeeper/internal/audit"
+	"gophkeeper/internal/blobstore"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/keyring"
+	"gophkeeper/internal/lockout"
 	"gophkeeper/internal/sessionstorage"
 	"gophkeeper/internal/storage"
 	"gophkeeper/internal/utils"
@@ -35,6 +40,22 @@ func GetUserId(ctx context.Context) string {
 	return ""
 }
 
+// GetDevice - returns name of the client device from metadata
+func GetDevice(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	value := md.Get("device")
+	if len(value) == 0 {
+		return ""
+	}
+	if len(value[0]) > maxDeviceLen {
+		return value[0][:maxDeviceLen]
+	}
+	return value[0]
+}
+
 // mapErr - maps err from storage to grpc error codes
 func mapErr(err error) error {
 	if err == storage.ErrDuplicate {
@@ -46,86 +67,256 @@ func mapErr(err error) error {
 	if err == storage.ErrNotFound {
 		return status.Errorf(codes.NotFound, "not found")
 	}
+	if err == storage.ErrCorrupted {
+		return status.Errorf(codes.DataLoss, "data corrupted")
+	}
+	if err == storage.ErrTOTPEnabled {
+		return status.Errorf(codes.FailedPrecondition, "two-factor authentication is already enabled")
+	}
 	return status.Errorf(codes.Internal, "internal error")
 }
 
+// toProto - converts note to its grpc representation
+func toProto(data datamodels.Data) *pb.Data {
+	return &pb.Data{
+		DataId:    data.DataID,
+		Data:      data.Data,
+		MetaInfo:  data.Metadata,
+		DataBlob:  data.DataBlob,
+		MetaBlob:  data.MetaBlob,
+		KeyId:     data.KeyID,
+		Deleted:   data.Deleted,
+		ChangedAt: timestamppb.New(data.ChangedAt),
+		Purged:    data.Purged,
+	}
+}
+
+const (
+	// sessionCleanupInterval - how often expired sessions are removed
+	sessionCleanupInterval = 10 * time.Minute
+	// maxDeviceLen - longest device name saved with a session
+	maxDeviceLen = 255
+)
+
 // GophKeeperServer is the gRPC server implementation for GophKeeper.
 type GophKeeperServer struct {
 	pb.UnimplementedGophkeeperServer
-	db    storage.Storage
+	db    storage.ServerStorage
 	users sessionstorage.SessionStorage
+	// challenges - users which passed password check and have to enter the second factor
+	challenges sessionstorage.SessionStorage
+	// attempts - failed authentication attempts of logins and addresses
+	attempts   lockout.Counter
+	adminToken string
+	audit      audit.Log
+	// blobs - chunks of uploaded files
+	blobs blobstore.Store
 }
 
-// Init initializes the gRPC server.
-func NewGophKeeperServer() GophKeeperServer {
+// NewGophKeeperServer initializes the gRPC server.
+func NewGophKeeperServer(cfg config.Server) GophKeeperServer {
 	var err error
 	var g GophKeeperServer
-	g.db, err = storage.NewDBStorage("postgresql://localhost:5432/shvm")
-	g.users = sessionstorage.NewAuthUsersStorage()
+	ring, err := loadKeyring(cfg)
+	if err != nil {
+		log.Fatalf("err loading keyring: %v", err)
+	}
+	db, err := storage.NewDBStorage(cfg.DatabaseDSN, ring)
 	if err != nil {
 		log.Fatalf("err pinging db")
 	}
+	g.db = db
+	switch cfg.SessionStore {
+	case "postgres":
+		g.users = sessionstorage.NewDBSessionStorage(db.DB(), cfg.SessionTTL)
+		g.challenges = sessionstorage.NewDBChallengeStorage(db.DB(), challengeTTL)
+	case "memory":
+		g.users = sessionstorage.NewAuthUsersStorageTTL(cfg.SessionTTL)
+		g.challenges = sessionstorage.NewAuthUsersStorageTTL(challengeTTL)
+	default:
+		log.Fatalf("unknown session storage %q", cfg.SessionStore)
+	}
+	switch cfg.LockoutStore {
+	case "postgres":
+		g.attempts = lockout.NewDB(db.DB(), lockout.DefaultPolicy)
+	case "memory":
+		g.attempts = lockout.NewMemory(lockout.DefaultPolicy)
+	default:
+		log.Fatalf("unknown lockout storage %q", cfg.LockoutStore)
+	}
+	switch cfg.BlobStore {
+	case "postgres":
+		g.blobs = blobstore.NewDB(db.DB())
+	case "memory":
+		g.blobs = blobstore.NewMemory()
+	default:
+		log.Fatalf("unknown blob storage %q", cfg.BlobStore)
+	}
+	g.adminToken = cfg.AdminToken
+	g.audit = audit.NewDB(db.DB())
+	go g.cleanupSessions()
+	go g.cleanupUploads(cfg.UploadTTL)
+	if cfg.RevisionsKeep > 0 || cfg.RevisionsMaxAge > 0 {
+		go g.cleanupRevisions(cfg.RevisionsKeep, cfg.RevisionsMaxAge)
+	}
+	if cfg.TrashTTL > 0 {
+		go g.cleanupTrash(cfg.TrashTTL)
+	}
+	if cfg.RotateKeys {
+		go func() {
+			if err := g.db.RotateKeys(context.Background()); err != nil {
+				log.Printf("key rotation stopped: %v", err)
+			}
+		}()
+	}
 	return g
 }
 
+// cleanupSessions - periodically removes expired sessions
+func (g *GophKeeperServer) cleanupSessions() {
+	for range time.Tick(sessionCleanupInterval) {
+		if err := g.users.Cleanup(); err != nil {
+			log.Printf("session cleanup failed: %v", err)
+		}
+		if err := g.challenges.Cleanup(); err != nil {
+			log.Printf("challenge cleanup failed: %v", err)
+		}
+	}
+}
+
+// loadKeyring - reads master keys from file or environment, nil means that no keyring is configured
+func loadKeyring(cfg config.Server) (*keyring.Keyring, error) {
+	if cfg.KeyringFile != "" {
+		return keyring.Load(cfg.KeyringFile)
+	}
+	if cfg.Keyring != "" {
+		return keyring.Parse(cfg.Keyring)
+	}
+	log.Println("no keyring configured, notes are encrypted with the built-in server key")
+	return nil, nil
+}
+
 // Auth handles the authentication request.
 func (g *GophKeeperServer) Auth(ctx context.Context, in *pb.AuthLoginRequest) (*pb.AuthLoginResponse, error) {
 	var resp pb.AuthLoginResponse
-	passHash := utils.GetMD5Hash(in.Password)
-	err := g.db.Auth(in.Login, passHash)
+	keys := addressKeys(ctx)
+	if err := g.checkLocked(keys...); err != nil {
+		return nil, err
+	}
+	err := g.db.Auth(in.Login, in.Password)
+	if err == storage.ErrDuplicate {
+		// probing for existing logins is counted like password guessing
+		g.registerFailure(keys...)
+	}
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	id, err := g.db.Login(in.Login, passHash)
+	id, err := g.db.Login(in.Login, in.Password)
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	token := utils.GenerateRandomString(5)
-	if err = g.users.AddUser(token, id); err != nil {
+	resp.ExpiresAt, err = g.newSession(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 	resp.Id = id
-	md2 := metadata.New(map[string]string{"userid": token})
-	outgoingCtx := metadata.NewOutgoingContext(ctx, md2)
-	err = grpc.SetHeader(outgoingCtx, md2)
-	if err != nil {
-		return nil, status.Error(codes.Internal, "SetHeader err")
-	}
 	return &resp, nil
 }
 
 // Login handles the login request.
+// Users with two-factor authentication get a challenge, session is issued by VerifySecondFactor.
 func (g *GophKeeperServer) Login(ctx context.Context, in *pb.AuthLoginRequest) (*pb.AuthLoginResponse, error) {
 	var resp pb.AuthLoginResponse
-	passHash := utils.GetMD5Hash(in.Password)
-	id, err := g.db.Login(in.Login, passHash)
+	keys := attemptKeys(ctx, loginKey(in.Login))
+	if err := g.checkLocked(keys...); err != nil {
+		return nil, err
+	}
+	id, err := g.db.Login(in.Login, in.Password)
+	if err == storage.ErrWrongPassword || err == storage.ErrNotFound {
+		g.registerFailure(keys...)
+	}
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	token := utils.GenerateRandomString(5)
-	err = g.users.AddUser(token, id)
+	g.resetAttempts(loginKey(in.Login))
+	required, err := g.secondFactorRequired(id)
+	if err != nil {
+		return nil, err
+	}
+	if required {
+		resp.Challenge, err = utils.GenerateToken()
+		if err != nil {
+			return nil, status.Error(codes.Internal, "token generation err")
+		}
+		if _, err = g.challenges.AddUser(resp.Challenge, id, GetDevice(ctx)); err != nil {
+			return nil, status.Error(codes.Internal, "session err")
+		}
+		return &resp, nil
+	}
+	if err = g.vaultKeys(&resp, id); err != nil {
+		return nil, err
+	}
+	resp.ExpiresAt, err = g.newSession(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	resp.Id = id
-	header := metadata.Pairs("userid", token)
-	grpc.SetHeader(ctx, header)
+	return &resp, nil
+}
 
+// newSession - creates session token of the user and sends it in the userid header
+func (g *GophKeeperServer) newSession(ctx context.Context, id uint32) (*timestamppb.Timestamp, error) {
+	token, err := utils.GenerateToken()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "token generation err")
+	}
+	expiresAt, err := g.users.AddUser(token, id, GetDevice(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "session err")
+	}
+	err = grpc.SetHeader(ctx, metadata.Pairs("userid", token))
+	if err != nil {
+		return nil, status.Error(codes.Internal, "SetHeader err")
+	}
+	return timestamppb.New(expiresAt), nil
+}
+
+// Refresh replaces valid session token with a new one, the old token stops working.
+func (g *GophKeeperServer) Refresh(ctx context.Context, in *emptypb.Empty) (*pb.SessionResponse, error) {
+	var resp pb.SessionResponse
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp.ExpiresAt, err = g.newSession(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.users.DelUser(p.Token); err != nil {
+		return nil, status.Error(codes.Internal, "session err")
+	}
 	return &resp, nil
 }
 
+// Logout removes session token.
+func (g *GophKeeperServer) Logout(ctx context.Context, in *emptypb.Empty) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.users.DelUser(p.Token); err != nil {
+		return nil, status.Error(codes.Internal, "session err")
+	}
+	return new(emptypb.Empty), nil
+}
+
 // AddData handles the request to add data.
 func (g *GophKeeperServer) AddData(ctx context.Context, in *pb.AddDataRequest) (*emptypb.Empty, error) {
-	//TODO хранить зашифровано
-	token := GetUserId(ctx)
-	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
-	}
-	id, err := g.users.GetUser(token)
+	p, err := principal(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, err
 	}
-	err = g.db.AddData(datamodels.Data{UserID: id, DataID: in.Data.DataId, Data: in.Data.Data, Metadata: in.Data.MetaInfo, ChangedAt: time.Now()})
+	err = g.db.AddData(datamodels.Data{UserID: p.UserID, DataID: in.Data.DataId, Data: in.Data.Data, Metadata: in.Data.MetaInfo, DataBlob: in.Data.DataBlob, MetaBlob: in.Data.MetaBlob, KeyID: in.Data.KeyId, ChangedAt: time.Now()})
 	if err != nil {
 		return nil, mapErr(err)
 	}
@@ -135,33 +326,25 @@ func (g *GophKeeperServer) AddData(ctx context.Context, in *pb.AddDataRequest) (
 // GetData handles the request to get data.
 func (g *GophKeeperServer) GetData(ctx context.Context, in *pb.GetDataRequest) (*pb.GetDataResponse, error) {
 	var resp pb.GetDataResponse
-	token := GetUserId(ctx)
-	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
-	}
-	id, err := g.users.GetUser(token)
+	p, err := principal(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, err
 	}
-	data, err := g.db.GetData(in.DataId, id)
+	data, err := g.db.GetData(in.DataId, p.UserID)
 	if err != nil {
 		return nil, mapErr(err)
 	}
-	resp.Data = &pb.Data{DataId: in.DataId, Data: data.Data, MetaInfo: data.Metadata, ChangedAt: timestamppb.New(data.ChangedAt), Deleted: false}
+	resp.Data = toProto(data)
 	return &resp, nil
 }
 
 // DelData handles the request to delete data.
 func (g *GophKeeperServer) DelData(ctx context.Context, in *pb.GetDataRequest) (*emptypb.Empty, error) {
-	token := GetUserId(ctx)
-	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
-	}
-	id, err := g.users.GetUser(token)
+	p, err := principal(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, err
 	}
-	err = g.db.DelData(in.DataId, id)
+	err = g.db.DelData(in.DataId, p.UserID)
 	if err != nil {
 		return nil, mapErr(err)
 	}
@@ -171,21 +354,21 @@ func (g *GophKeeperServer) DelData(ctx context.Context, in *pb.GetDataRequest) (
 // Sync handles the synchronization request.
 func (g *GophKeeperServer) Sync(ctx context.Context, in *emptypb.Empty) (*pb.SynchronizationResponse, error) {
 	var resp pb.SynchronizationResponse
-	token := GetUserId(ctx)
-	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
-	}
-	id, err := g.users.GetUser(token)
+	p, err := principal(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, err
 	}
-	data, err := g.db.Sync(id)
+	data, err := g.db.Sync(p.UserID)
 	if err != nil {
 		return nil, mapErr(err)
 	}
+	if err = g.db.DeviceSynced(p.UserID, GetDevice(ctx)); err != nil {
+		// tombstones are just kept longer
+		log.Printf("saving sync of user %d failed: %v", p.UserID, err)
+	}
 	if data != nil {
 		for _, v := range data {
-			resp.Data = append(resp.Data, &pb.Data{DataId: v.DataID, Data: v.Data, MetaInfo: v.Metadata, Deleted: v.Deleted, ChangedAt: timestamppb.New(v.ChangedAt)})
+			resp.Data = append(resp.Data, toProto(v))
 		}
 	}
 	return &resp, nil
@@ -194,15 +377,11 @@ func (g *GophKeeperServer) Sync(ctx context.Context, in *emptypb.Empty) (*pb.Syn
 
 // ClientSync handles the client synchronization request.
 func (g *GophKeeperServer) ClientSync(ctx context.Context, in *pb.ClientSyncRequest) (*emptypb.Empty, error) {
-	token := GetUserId(ctx)
-	if token == "" {
-		return nil, status.Error(codes.Unauthenticated, "token is empty")
-	}
-	id, err := g.users.GetUser(token)
+	p, err := principal(ctx)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+		return nil, err
 	}
-	err = g.db.ClientSync(id, in.Data)
+	err = g.db.ClientSync(p.UserID, in.Data)
 	if err != nil {
 		return nil, mapErr(err)
 	}
@@ -0,0 +1,120 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"crypto/subtle"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods - methods which can be called without session token, all other methods are authenticated
+var publicMethods = map[string]bool{
+	pb.Gophkeeper_Login_FullMethodName: true,
+	pb.Gophkeeper_Auth_FullMethodName:  true,
+	// challenge from Login is checked by the handler
+	pb.Gophkeeper_VerifySecondFactor_FullMethodName: true,
+}
+
+// adminMethods - methods which require admin token instead of user session
+var adminMethods = map[string]bool{
+	pb.Gophkeeper_UnlockAccount_FullMethodName: true,
+}
+
+// Principal - authenticated caller of a method
+type Principal struct {
+	UserID uint32
+	Token  string
+}
+
+// principalKey - context key of Principal
+type principalKey struct{}
+
+// auditCallerKey - context key of the Principal slot filled by UnaryAuth for UnaryAudit, which runs before it
+type auditCallerKey struct{}
+
+// PrincipalFromContext returns caller put in the context by the auth interceptors.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// principal - returns caller of the handler, handlers called without interceptors are rejected
+func principal(ctx context.Context) (Principal, error) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return Principal{}, status.Error(codes.Unauthenticated, "user unauthenticated")
+	}
+	return p, nil
+}
+
+// authenticate - resolves session token of the call into Principal
+func (g *GophKeeperServer) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+	if adminMethods[method] {
+		return ctx, g.checkAdmin(ctx)
+	}
+	token := GetUserId(ctx)
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "token is empty")
+	}
+	id, err := g.users.GetUser(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "user unauthenticated")
+	}
+	return context.WithValue(ctx, principalKey{}, Principal{UserID: id, Token: token}), nil
+}
+
+// checkAdmin - compares admin-token metadata with the configured token, admin methods are disabled without it
+func (g *GophKeeperServer) checkAdmin(ctx context.Context) error {
+	if g.adminToken == "" {
+		return status.Error(codes.PermissionDenied, "admin methods are disabled")
+	}
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("admin-token")) > 0 {
+		token = md.Get("admin-token")[0]
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(g.adminToken)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid admin token")
+	}
+	return nil
+}
+
+// UnaryAuth - unary server interceptor which authenticates every non-public method
+func (g *GophKeeperServer) UnaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := g.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if slot, ok := ctx.Value(auditCallerKey{}).(*Principal); ok {
+		*slot, _ = PrincipalFromContext(ctx)
+	}
+	return handler(ctx, req)
+}
+
+// authStream - server stream with context of authenticated caller
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns context with Principal.
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamAuth - stream server interceptor which authenticates every non-public method
+func (g *GophKeeperServer) StreamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := g.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
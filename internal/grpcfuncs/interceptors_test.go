@@ -0,0 +1,62 @@
+package grpcfuncs
+
+import (
+	"context"
+	"testing"
+
+	"gophkeeper/internal/sessionstorage"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryAuth(t *testing.T) {
+	g := GophKeeperServer{users: sessionstorage.NewAuthUsersStorage(), adminToken: "admin"}
+	_, err := g.users.AddUser("token", 7, "laptop")
+	assert.NoError(t, err)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		p, ok := PrincipalFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Internal, "no principal")
+		}
+		return p, nil
+	}
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		admin  string
+		code   codes.Code
+		want   Principal
+	}{
+		{name: "public method", method: pb.Gophkeeper_Login_FullMethodName, code: codes.Internal},
+		{name: "no token", method: pb.Gophkeeper_Sync_FullMethodName, code: codes.Unauthenticated},
+		{name: "unknown token", method: pb.Gophkeeper_Sync_FullMethodName, token: "other", code: codes.Unauthenticated},
+		{name: "valid token", method: pb.Gophkeeper_Sync_FullMethodName, token: "token", code: codes.OK, want: Principal{UserID: 7, Token: "token"}},
+		{name: "admin method with session", method: pb.Gophkeeper_UnlockAccount_FullMethodName, token: "token", code: codes.PermissionDenied},
+		{name: "wrong admin token", method: pb.Gophkeeper_UnlockAccount_FullMethodName, admin: "guess", code: codes.PermissionDenied},
+		{name: "admin token", method: pb.Gophkeeper_UnlockAccount_FullMethodName, admin: "admin", code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			md := metadata.MD{}
+			if tt.token != "" {
+				md.Set("userid", tt.token)
+			}
+			if tt.admin != "" {
+				md.Set("admin-token", tt.admin)
+			}
+			ctx = metadata.NewIncomingContext(ctx, md)
+			resp, err := g.UnaryAuth(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, handler)
+			assert.Equal(t, tt.code, status.Code(err))
+			if tt.code == codes.OK {
+				assert.Equal(t, tt.want, resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// loginKey - counter of password attempts for the login
+func loginKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
+
+// ipKey - counter of attempts made from the address
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// userKey - counter of second factor attempts of the user
+func userKey(id uint32) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// peerIP - returns address of the caller without port
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// addressKeys - returns counter of the caller address if it is known
+func addressKeys(ctx context.Context) []string {
+	if ip := peerIP(ctx); ip != "" {
+		return []string{ipKey(ip)}
+	}
+	return nil
+}
+
+// attemptKeys - returns the key with the caller address key
+func attemptKeys(ctx context.Context, key string) []string {
+	return append([]string{key}, addressKeys(ctx)...)
+}
+
+// lockedError - ResourceExhausted status telling the client when to retry
+func lockedError(until time.Time) error {
+	st := status.New(codes.ResourceExhausted, "too many failed attempts")
+	detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Until(until).Round(time.Second))})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// checkLocked - returns lockedError if any of the keys is locked
+func (g *GophKeeperServer) checkLocked(keys ...string) error {
+	now := time.Now()
+	var until time.Time
+	for _, key := range keys {
+		locked, err := g.attempts.LockedUntil(key, now)
+		if err != nil {
+			log.Printf("lockout check failed: %v", err)
+			return status.Error(codes.Internal, "internal error")
+		}
+		if locked.After(until) {
+			until = locked
+		}
+	}
+	if until.IsZero() {
+		return nil
+	}
+	return lockedError(until)
+}
+
+// registerFailure - counts failed attempt for every key
+func (g *GophKeeperServer) registerFailure(keys ...string) {
+	now := time.Now()
+	for _, key := range keys {
+		if _, err := g.attempts.Fail(key, now); err != nil {
+			log.Printf("lockout update failed: %v", err)
+		}
+	}
+}
+
+// resetAttempts - forgets failed attempts of the key after successful authentication
+func (g *GophKeeperServer) resetAttempts(key string) {
+	if err := g.attempts.Reset(key); err != nil {
+		log.Printf("lockout reset failed: %v", err)
+	}
+}
+
+// guardSecondFactor - checkSecondFactor with attempts counted for the user and the caller address
+func (g *GophKeeperServer) guardSecondFactor(ctx context.Context, id uint32, code string) error {
+	keys := attemptKeys(ctx, userKey(id))
+	if err := g.checkLocked(keys...); err != nil {
+		return err
+	}
+	err := g.checkSecondFactor(id, code)
+	if status.Code(err) == codes.Unauthenticated {
+		g.registerFailure(keys...)
+	}
+	if err == nil {
+		g.resetAttempts(userKey(id))
+	}
+	return err
+}
+
+// UnlockAccount forgets failed attempts of the login and of the address.
+func (g *GophKeeperServer) UnlockAccount(ctx context.Context, in *pb.UnlockRequest) (*emptypb.Empty, error) {
+	if in.Login == "" && in.Ip == "" {
+		return nil, status.Error(codes.InvalidArgument, "login or ip required")
+	}
+	var keys []string
+	if in.Login != "" {
+		keys = append(keys, loginKey(in.Login))
+		if id, err := g.db.UserID(in.Login); err == nil {
+			keys = append(keys, userKey(id))
+		}
+	}
+	if in.Ip != "" {
+		keys = append(keys, ipKey(in.Ip))
+	}
+	for _, key := range keys {
+		if err := g.attempts.Reset(key); err != nil {
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+	}
+	log.Printf("admin unlocked login %q ip %q", in.Login, in.Ip)
+	return new(emptypb.Empty), nil
+}
@@ -0,0 +1,40 @@
+package grpcfuncs
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/lockout"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckLocked(t *testing.T) {
+	g := GophKeeperServer{attempts: lockout.NewMemory(lockout.Policy{Free: 1, Base: time.Minute, Max: time.Hour, Window: time.Hour})}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 5000}})
+	keys := attemptKeys(ctx, loginKey("Test"))
+	assert.Equal(t, []string{"login:test", "ip:10.0.0.1"}, keys)
+
+	g.registerFailure(keys...)
+	assert.NoError(t, g.checkLocked(keys...))
+	g.registerFailure(keys...)
+	err := g.checkLocked(loginKey("test"))
+	st := status.Convert(err)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+	if assert.Len(t, st.Details(), 1) {
+		info, ok := st.Details()[0].(*errdetails.RetryInfo)
+		assert.True(t, ok)
+		assert.Equal(t, time.Minute, info.RetryDelay.AsDuration())
+	}
+	// another login from the same address is locked too
+	assert.Equal(t, codes.ResourceExhausted, status.Code(g.checkLocked(attemptKeys(ctx, loginKey("other"))...)))
+
+	g.resetAttempts(loginKey("test"))
+	assert.NoError(t, g.checkLocked(loginKey("test")))
+}
@@ -0,0 +1,55 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// vaultKeys - fills salt of the vault key and previous vault keys of the user
+func (g *GophKeeperServer) vaultKeys(resp *pb.AuthLoginResponse, id uint32) error {
+	var err error
+	resp.VaultSalt, err = g.db.VaultSalt(id)
+	if err != nil {
+		return mapErr(err)
+	}
+	resp.KeyHistory, err = g.db.KeyHistory(id)
+	if err != nil {
+		return mapErr(err)
+	}
+	return nil
+}
+
+// ChangePassword replaces password of the caller, all other sessions of the user are revoked.
+// Wrong old password is counted like a failed login.
+func (g *GophKeeperServer) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if in.NewPassword == "" || len(in.VaultSalt) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "new password and vault salt required")
+	}
+	keys := attemptKeys(ctx, userKey(p.UserID))
+	if err = g.checkLocked(keys...); err != nil {
+		return nil, err
+	}
+	err = g.db.ChangePassword(p.UserID, in.OldPassword, in.NewPassword, in.VaultSalt, in.KeyHistory)
+	if err == storage.ErrWrongPassword {
+		g.registerFailure(keys...)
+	}
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	g.resetAttempts(userKey(p.UserID))
+	if err = g.users.DelUserSessions(p.UserID, p.Token); err != nil {
+		return nil, status.Error(codes.Internal, "session err")
+	}
+	return new(emptypb.Empty), nil
+}
@@ -0,0 +1,72 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// revisionCleanupInterval - how often revisions beyond the retention limits are removed
+const revisionCleanupInterval = time.Hour
+
+// ListRevisions returns previous versions of the note of the caller from the newest.
+func (g *GophKeeperServer) ListRevisions(ctx context.Context, in *pb.GetDataRequest) (*pb.ListRevisionsResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := g.db.ListRevisions(p.UserID, in.DataId)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	resp := &pb.ListRevisionsResponse{Revisions: make([]*pb.Revision, 0, len(revisions))}
+	for _, r := range revisions {
+		resp.Revisions = append(resp.Revisions, &pb.Revision{Id: r.ID, ChangedAt: timestamppb.New(r.ChangedAt), RevisedAt: timestamppb.New(r.RevisedAt), Deleted: r.Deleted})
+	}
+	return resp, nil
+}
+
+// GetRevision returns previous version of the note of the caller.
+func (g *GophKeeperServer) GetRevision(ctx context.Context, in *pb.RevisionRequest) (*pb.GetDataResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := g.db.GetRevision(p.UserID, in.DataId, in.Revision)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.GetDataResponse{Data: toProto(data)}, nil
+}
+
+// RestoreRevision makes previous version of the note of the caller the current one.
+func (g *GophKeeperServer) RestoreRevision(ctx context.Context, in *pb.RevisionRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.db.RestoreRevision(p.UserID, in.DataId, in.Revision); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// cleanupRevisions - periodically removes revisions beyond the retention limits
+func (g *GophKeeperServer) cleanupRevisions(keep int, maxAge time.Duration) {
+	for range time.Tick(revisionCleanupInterval) {
+		n, err := g.db.PruneRevisions(keep, maxAge)
+		if err != nil {
+			log.Printf("revision cleanup failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("removed %d old revisions", n)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"time"
+
+	"gophkeeper/internal/otp"
+	"gophkeeper/internal/storage"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const (
+	// challengeTTL - time given to enter the second factor after the password was accepted
+	challengeTTL = 5 * time.Minute
+	// recoveryCodes - amount of recovery codes issued when two-factor authentication is enabled
+	recoveryCodes = 10
+	// totpSkew - accepted time steps before and after the current one
+	totpSkew = 1
+)
+
+// totpKey - TOTP key of the secret with default parameters
+func totpKey(secret []byte) otp.Key {
+	return otp.Key{Secret: secret, Algorithm: "SHA1", Digits: otp.DefaultDigits, Period: otp.DefaultPeriod}
+}
+
+// checkSecondFactor - accepts TOTP code or unused recovery code of the user, every code works only once
+func (g *GophKeeperServer) checkSecondFactor(id uint32, code string) error {
+	secret, confirmed, err := g.db.TOTP(id)
+	if err != nil {
+		return mapErr(err)
+	}
+	if !confirmed {
+		return status.Error(codes.FailedPrecondition, "two-factor authentication is not enabled")
+	}
+	if step, ok := totpKey(secret).Validate(code, time.Now(), totpSkew); ok {
+		fresh, err := g.db.UseTOTPStep(id, step)
+		if err != nil {
+			return mapErr(err)
+		}
+		if fresh {
+			return nil
+		}
+		return status.Error(codes.Unauthenticated, "code already used")
+	}
+	ok, err := g.db.UseRecoveryCode(id, otp.HashRecoveryCode(code))
+	if err != nil {
+		return mapErr(err)
+	}
+	if !ok {
+		return status.Error(codes.Unauthenticated, "invalid code")
+	}
+	return nil
+}
+
+// secondFactorRequired - returns true if the user confirmed TOTP enrollment
+func (g *GophKeeperServer) secondFactorRequired(id uint32) (bool, error) {
+	_, confirmed, err := g.db.TOTP(id)
+	if err == storage.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, mapErr(err)
+	}
+	return confirmed, nil
+}
+
+// VerifySecondFactor completes Login of a user with two-factor authentication, session is issued only after the code is accepted.
+func (g *GophKeeperServer) VerifySecondFactor(ctx context.Context, in *pb.SecondFactorRequest) (*pb.AuthLoginResponse, error) {
+	var resp pb.AuthLoginResponse
+	id, err := g.challenges.GetUser(in.Challenge)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "challenge expired")
+	}
+	if err = g.guardSecondFactor(ctx, id, in.Code); err != nil {
+		return nil, err
+	}
+	if err = g.challenges.DelUser(in.Challenge); err != nil {
+		return nil, status.Error(codes.Internal, "session err")
+	}
+	if err = g.vaultKeys(&resp, id); err != nil {
+		return nil, err
+	}
+	resp.ExpiresAt, err = g.newSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resp.Id = id
+	return &resp, nil
+}
+
+// EnrollTOTP generates new TOTP secret of the caller, it is used only after ConfirmTOTP.
+func (g *GophKeeperServer) EnrollTOTP(ctx context.Context, in *emptypb.Empty) (*pb.EnrollTOTPResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := otp.NewKey()
+	if err != nil {
+		return nil, status.Error(codes.Internal, "secret generation err")
+	}
+	if err = g.db.SetTOTP(p.UserID, key.Secret); err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.EnrollTOTPResponse{Secret: key.EncodedSecret()}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication when the code matches enrolled secret and returns recovery codes.
+func (g *GophKeeperServer) ConfirmTOTP(ctx context.Context, in *pb.TOTPCodeRequest) (*pb.ConfirmTOTPResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	secret, confirmed, err := g.db.TOTP(p.UserID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	if confirmed {
+		return nil, mapErr(storage.ErrTOTPEnabled)
+	}
+	step, ok := totpKey(secret).Validate(in.Code, time.Now(), totpSkew)
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "invalid code")
+	}
+	codeList, err := otp.NewRecoveryCodes(recoveryCodes)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "recovery codes generation err")
+	}
+	hashes := make([]string, 0, len(codeList))
+	for _, code := range codeList {
+		hashes = append(hashes, otp.HashRecoveryCode(code))
+	}
+	if err = g.db.ConfirmTOTP(p.UserID, hashes); err != nil {
+		return nil, mapErr(err)
+	}
+	if _, err = g.db.UseTOTPStep(p.UserID, step); err != nil {
+		return nil, mapErr(err)
+	}
+	return &pb.ConfirmTOTPResponse{RecoveryCodes: codeList}, nil
+}
+
+// DisableTOTP turns two-factor authentication off, current TOTP code or recovery code is required.
+func (g *GophKeeperServer) DisableTOTP(ctx context.Context, in *pb.TOTPCodeRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.guardSecondFactor(ctx, p.UserID, in.Code); err != nil {
+		return nil, err
+	}
+	if err = g.db.DisableTOTP(p.UserID); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
@@ -0,0 +1,96 @@
+// Package grpcfuncs provides server-side gRPC functions for authentication, data management, and synchronization.
+package grpcfuncs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// trashCleanupInterval - how often old tombstones are removed
+const trashCleanupInterval = time.Hour
+
+// ListDeleted returns notes in the trash of the caller.
+func (g *GophKeeperServer) ListDeleted(ctx context.Context, in *emptypb.Empty) (*pb.SynchronizationResponse, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := g.db.ListDeleted(p.UserID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var resp pb.SynchronizationResponse
+	for _, v := range data {
+		resp.Data = append(resp.Data, toProto(v))
+	}
+	return &resp, nil
+}
+
+// Undelete restores the note of the caller from the trash.
+func (g *GophKeeperServer) Undelete(ctx context.Context, in *pb.GetDataRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = g.db.Undelete(p.UserID, in.DataId); err != nil {
+		return nil, mapErr(err)
+	}
+	return new(emptypb.Empty), nil
+}
+
+// Purge erases the note of the caller from the trash with its uploaded files, the whole trash is erased only when all is set.
+func (g *GophKeeperServer) Purge(ctx context.Context, in *pb.PurgeRequest) (*emptypb.Empty, error) {
+	p, err := principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if (in.DataId == "") != in.All {
+		return nil, status.Error(codes.InvalidArgument, "either data id or all must be set")
+	}
+	purged, err := g.db.Purge(p.UserID, in.DataId)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	for _, id := range purged {
+		if err = g.deleteNoteBlobs(p.UserID, id); err != nil {
+			return nil, mapBlobErr(err)
+		}
+	}
+	return new(emptypb.Empty), nil
+}
+
+// deleteNoteBlobs - erases files uploaded for the note, files left by a failed purge are erased with the tombstone
+func (g *GophKeeperServer) deleteNoteBlobs(userID uint32, dataID string) error {
+	// blobs uploaded before they were linked to notes have empty data id
+	if dataID == "" {
+		return nil
+	}
+	_, err := g.blobs.DeleteNote(userID, dataID)
+	return err
+}
+
+// cleanupTrash - periodically removes tombstones older than maxAge which all devices have synced
+func (g *GophKeeperServer) cleanupTrash(maxAge time.Duration) {
+	for range time.Tick(trashCleanupInterval) {
+		removed, err := g.db.RemoveTombstones(maxAge)
+		if err != nil {
+			log.Printf("trash cleanup failed: %v", err)
+			continue
+		}
+		for _, v := range removed {
+			if err = g.deleteNoteBlobs(v.UserID, v.DataID); err != nil {
+				log.Printf("erasing files of note %s failed: %v", v.DataID, err)
+			}
+		}
+		if len(removed) > 0 {
+			log.Printf("removed %d old tombstones", len(removed))
+		}
+	}
+}
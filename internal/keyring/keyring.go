@@ -0,0 +1,126 @@
+// Package keyring provides master keys of the server used to wrap per-user data keys.
+package keyring
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gophkeeper/internal/utils"
+)
+
+// LegacyID - id reserved for the built-in server key which wraps data keys of servers started without keyring
+const LegacyID uint32 = 0
+
+// Module errors
+var (
+	ErrEmpty      = errors.New("keyring has no keys")
+	ErrUnknownKey = errors.New("unknown master key")
+	ErrReserved   = errors.New("master key id 0 is reserved for the built-in server key")
+	ErrExists     = errors.New("master key id is already in the keyring")
+)
+
+// Keyring - set of master keys identified by id, the key with the highest id is active.
+// Retired keys only unwrap data keys and are never active.
+type Keyring struct {
+	keys   map[uint32]utils.Cipher
+	active uint32
+}
+
+// New creates keyring from master keys, every key must be 16, 24 or 32 bytes long.
+func New(keys map[uint32][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmpty
+	}
+	k := &Keyring{keys: make(map[uint32]utils.Cipher, len(keys))}
+	first := true
+	for id, key := range keys {
+		c, err := utils.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %d: %w", id, err)
+		}
+		k.keys[id] = c
+		if first || id > k.active {
+			k.active = id
+			first = false
+		}
+	}
+	return k, nil
+}
+
+// Parse reads keyring from text with "id:base64key" entries separated by new lines or commas.
+func Parse(text string) (*Keyring, error) {
+	keys := make(map[uint32][]byte)
+	entries := strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' })
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		idText, keyText, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid keyring entry %q", entry)
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(idText), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key id %q", idText)
+		}
+		if uint32(id) == LegacyID {
+			return nil, ErrReserved
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyText))
+		if err != nil {
+			return nil, fmt.Errorf("invalid master key %d: %w", id, err)
+		}
+		keys[uint32(id)] = key
+	}
+	return New(keys)
+}
+
+// Load reads keyring from file, see Parse for the format.
+func Load(path string) (*Keyring, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(text))
+}
+
+// AddRetired adds master key which only unwraps data keys wrapped by it earlier, the active key doesn't change.
+func (k *Keyring) AddRetired(id uint32, key []byte) error {
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("master key %d: %w", id, ErrExists)
+	}
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("master key %d: %w", id, err)
+	}
+	k.keys[id] = c
+	return nil
+}
+
+// ActiveID returns id of the master key used to wrap new data keys.
+func (k *Keyring) ActiveID() uint32 {
+	return k.active
+}
+
+// Wrap encrypts data key with the active master key and returns id of that master key.
+func (k *Keyring) Wrap(dataKey []byte) (uint32, []byte, error) {
+	wrapped, err := k.keys[k.active].Seal(dataKey)
+	if err != nil {
+		return 0, nil, err
+	}
+	return k.active, wrapped, nil
+}
+
+// Unwrap decrypts data key wrapped by the master key with the id.
+func (k *Keyring) Unwrap(id uint32, wrapped []byte) ([]byte, error) {
+	c, ok := k.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return c.Open(wrapped)
+}
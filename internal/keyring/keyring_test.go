@@ -0,0 +1,68 @@
+package keyring
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+func ExampleParse() {
+	ring, err := Parse("1:cXdlcnR5dWlvcG1tYXNkZg==,2:YWxza2RqZmhnbmJ2Y21ydA==")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(ring.ActiveID())
+	//Output:
+	//2
+}
+
+func ExampleKeyring_Wrap() {
+	ring, err := Parse("1:cXdlcnR5dWlvcG1tYXNkZg==\n2:YWxza2RqZmhnbmJ2Y21ydA==")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	id, wrapped, err := ring.Wrap([]byte("data key"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	key, err := ring.Unwrap(id, wrapped)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(id, string(key))
+	_, err = ring.Unwrap(3, wrapped)
+	fmt.Println(errors.Is(err, ErrUnknownKey))
+	//Output:
+	//2 data key
+	//true
+}
+
+func ExampleKeyring_AddRetired() {
+	_, err := Parse("0:cXdlcnR5dWlvcG1tYXNkZg==")
+	fmt.Println(errors.Is(err, ErrReserved))
+	ring, err := Parse("1:cXdlcnR5dWlvcG1tYXNkZg==")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	legacy, err := New(map[uint32][]byte{LegacyID: []byte("YWxza2RqZmhnbmJ2")})
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, wrapped, err := legacy.Wrap([]byte("data key"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = ring.AddRetired(LegacyID, []byte("YWxza2RqZmhnbmJ2")); err != nil {
+		log.Fatalln(err)
+	}
+	key, err := ring.Unwrap(LegacyID, wrapped)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(ring.ActiveID(), string(key))
+	fmt.Println(errors.Is(ring.AddRetired(1, []byte("YWxza2RqZmhnbmJ2")), ErrExists))
+	//Output:
+	//true
+	//1 data key
+	//true
+}
@@ -0,0 +1,61 @@
+// Package lockout counts failed authentication attempts and locks keys with exponential backoff.
+package lockout
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// dbCounter is an implementation of Counter that keeps attempts in the PostgreSQL login_attempts table shared by all servers.
+type dbCounter struct {
+	db     *sql.DB
+	policy Policy
+}
+
+// NewDB creates Counter which keeps attempts in the database.
+func NewDB(db *sql.DB, policy Policy) Counter {
+	return &dbCounter{db: db, policy: policy}
+}
+
+// Fail registers failed attempt of the key, failures older than the policy window are forgotten.
+func (dc *dbCounter) Fail(key string, now time.Time) (time.Time, error) {
+	var failures int
+	err := dc.db.QueryRow(`insert into login_attempts (key, failures, last_failure) values ($1, 1, $2)
+ON CONFLICT (key) DO UPDATE SET failures = case when login_attempts.last_failure < $3 then 1 else login_attempts.failures + 1 end, last_failure=EXCLUDED.last_failure
+returning failures;`, key, now, now.Add(-dc.policy.Window)).Scan(&failures)
+	if err != nil {
+		return time.Time{}, err
+	}
+	delay := dc.policy.Delay(failures)
+	if delay == 0 {
+		return time.Time{}, nil
+	}
+	lockedUntil := now.Add(delay)
+	if _, err = dc.db.Exec("update login_attempts set locked_until=$2 where key=$1;", key, lockedUntil); err != nil {
+		return time.Time{}, err
+	}
+	return lockedUntil, nil
+}
+
+// LockedUntil returns end of the key lockout.
+func (dc *dbCounter) LockedUntil(key string, now time.Time) (time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := dc.db.QueryRow("select locked_until from login_attempts where key=$1;", key).Scan(&lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !lockedUntil.Valid || !now.Before(lockedUntil.Time) {
+		return time.Time{}, nil
+	}
+	return lockedUntil.Time, nil
+}
+
+// Reset forgets failed attempts of the key.
+func (dc *dbCounter) Reset(key string) error {
+	_, err := dc.db.Exec("delete from login_attempts where key=$1;", key)
+	return err
+}
@@ -0,0 +1,108 @@
+// Package lockout counts failed authentication attempts and locks keys with exponential backoff.
+package lockout
+
+import (
+	"sync"
+	"time"
+)
+
+// Counter - storage of failed attempts, keys are logins, peer addresses or anything else guessed by an attacker
+type Counter interface {
+	// Fail registers failed attempt of the key and returns time until which the key is locked.
+	Fail(key string, now time.Time) (time.Time, error)
+	// LockedUntil returns time until which the key is locked, zero time means that attempts are allowed.
+	LockedUntil(key string, now time.Time) (time.Time, error)
+	// Reset forgets failed attempts of the key.
+	Reset(key string) error
+}
+
+// Policy - how fast lockout grows with failed attempts
+type Policy struct {
+	// Free - failed attempts allowed without delay
+	Free int
+	// Base - lockout after the first attempt above Free, it doubles with every next failure
+	Base time.Duration
+	// Max - longest lockout
+	Max time.Duration
+	// Window - failures are forgotten when there were no attempts for this long
+	Window time.Duration
+}
+
+// DefaultPolicy - 5 free attempts, then from 1 second up to 15 minutes of lockout
+var DefaultPolicy = Policy{Free: 5, Base: time.Second, Max: 15 * time.Minute, Window: 24 * time.Hour}
+
+// Delay returns lockout after the number of failed attempts.
+func (p Policy) Delay(failures int) time.Duration {
+	if failures <= p.Free {
+		return 0
+	}
+	delay := p.Base
+	for i := p.Free + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= p.Max {
+			return p.Max
+		}
+	}
+	if delay > p.Max {
+		return p.Max
+	}
+	return delay
+}
+
+// entry - failed attempts of a key
+type entry struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// memoryCounter is an implementation of Counter that keeps attempts in memory of a single server.
+type memoryCounter struct {
+	policy  Policy
+	entries map[string]entry
+	mutex   sync.Mutex
+}
+
+// NewMemory creates Counter which keeps attempts in memory.
+func NewMemory(policy Policy) Counter {
+	return &memoryCounter{policy: policy, entries: make(map[string]entry)}
+}
+
+// Fail registers failed attempt of the key.
+func (mc *memoryCounter) Fail(key string, now time.Time) (time.Time, error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	e := mc.entries[key]
+	if now.Sub(e.lastFailure) > mc.policy.Window {
+		e = entry{}
+	}
+	e.failures++
+	e.lastFailure = now
+	if delay := mc.policy.Delay(e.failures); delay > 0 {
+		e.lockedUntil = now.Add(delay)
+	}
+	mc.entries[key] = e
+	return e.lockedUntil, nil
+}
+
+// LockedUntil returns end of the key lockout.
+func (mc *memoryCounter) LockedUntil(key string, now time.Time) (time.Time, error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	e, ok := mc.entries[key]
+	if !ok || !now.Before(e.lockedUntil) {
+		if ok && now.Sub(e.lastFailure) > mc.policy.Window {
+			delete(mc.entries, key)
+		}
+		return time.Time{}, nil
+	}
+	return e.lockedUntil, nil
+}
+
+// Reset forgets failed attempts of the key.
+func (mc *memoryCounter) Reset(key string) error {
+	mc.mutex.Lock()
+	delete(mc.entries, key)
+	mc.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,57 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Delay(t *testing.T) {
+	p := Policy{Free: 3, Base: time.Second, Max: 10 * time.Second, Window: time.Hour}
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 3, want: 0},
+		{failures: 4, want: time.Second},
+		{failures: 5, want: 2 * time.Second},
+		{failures: 7, want: 8 * time.Second},
+		{failures: 8, want: 10 * time.Second},
+		{failures: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, p.Delay(tt.failures), tt.failures)
+	}
+}
+
+func TestMemoryCounter(t *testing.T) {
+	c := NewMemory(Policy{Free: 1, Base: time.Minute, Max: time.Hour, Window: time.Hour})
+	now := time.Now()
+	until, err := c.Fail("login:test", now)
+	assert.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	until, err = c.Fail("login:test", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), until)
+	locked, err := c.LockedUntil("login:test", now.Add(time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, until, locked)
+	locked, err = c.LockedUntil("login:other", now)
+	assert.NoError(t, err)
+	assert.True(t, locked.IsZero())
+
+	// failures are forgotten after the window
+	until, err = c.Fail("login:test", now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	_, err = c.Fail("login:test", now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.NoError(t, c.Reset("login:test"))
+	locked, err = c.LockedUntil("login:test", now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, locked.IsZero())
+}
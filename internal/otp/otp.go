@@ -0,0 +1,190 @@
+// Package otp implements RFC 4226 HOTP and RFC 6238 TOTP one-time passwords.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default TOTP parameters used by authenticator apps
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+	secretLen     = 20
+)
+
+// Module errors
+var (
+	ErrSecret    = errors.New("invalid otp secret")
+	ErrAlgorithm = errors.New("unsupported otp algorithm")
+	ErrDigits    = errors.New("unsupported number of otp digits")
+	ErrURI       = errors.New("invalid otpauth URI, expected otpauth://totp/issuer:account?secret=...")
+)
+
+// b32 - encoding of secrets in otpauth URIs
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key - TOTP secret with its parameters
+type Key struct {
+	Secret []byte
+	// Algorithm - SHA1, SHA256 or SHA512
+	Algorithm string
+	Digits    int
+	Period    time.Duration
+}
+
+// NewKey generates random secret with default parameters.
+func NewKey() (Key, error) {
+	secret := make([]byte, secretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+	return Key{Secret: secret, Algorithm: "SHA1", Digits: DefaultDigits, Period: DefaultPeriod}, nil
+}
+
+// DecodeSecret - decodes base32 secret as it is shown to users, spaces and case are ignored
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	decoded, err := b32.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(decoded) == 0 {
+		return nil, ErrSecret
+	}
+	return decoded, nil
+}
+
+// EncodedSecret returns base32 secret to be typed into an authenticator app.
+func (k Key) EncodedSecret() string {
+	return b32.EncodeToString(k.Secret)
+}
+
+// URI returns otpauth URI of the key, authenticator apps import it from a QR code.
+func (k Key) URI(issuer string, account string) string {
+	v := url.Values{}
+	v.Set("secret", k.EncodedSecret())
+	v.Set("issuer", issuer)
+	v.Set("algorithm", k.Algorithm)
+	v.Set("digits", fmt.Sprint(k.Digits))
+	v.Set("period", fmt.Sprint(int(k.Period/time.Second)))
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + account, RawQuery: v.Encode()}
+	return u.String()
+}
+
+// ParseURI returns key, issuer and account of an otpauth URI exported by authenticator apps.
+// Only TOTP is supported, algorithm, digits and period are optional and default to values of the apps.
+func ParseURI(uri string) (Key, string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil || u.Scheme != "otpauth" || !strings.EqualFold(u.Host, "totp") {
+		return Key{}, "", "", ErrURI
+	}
+	q := u.Query()
+	secret, err := DecodeSecret(q.Get("secret"))
+	if err != nil {
+		return Key{}, "", "", err
+	}
+	k := Key{Secret: secret, Algorithm: strings.ToUpper(q.Get("algorithm")), Digits: DefaultDigits, Period: DefaultPeriod}
+	if k.Algorithm == "" {
+		k.Algorithm = "SHA1"
+	}
+	if v := q.Get("digits"); v != "" {
+		if k.Digits, err = strconv.Atoi(v); err != nil {
+			return Key{}, "", "", ErrDigits
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		period, err := strconv.Atoi(v)
+		if err != nil || period <= 0 {
+			return Key{}, "", "", ErrURI
+		}
+		k.Period = time.Duration(period) * time.Second
+	}
+	if _, err = k.Code(0); err != nil {
+		return Key{}, "", "", err
+	}
+	// label is "issuer:account" or just account, issuer parameter has priority
+	issuer, account := "", strings.TrimPrefix(u.Path, "/")
+	if i := strings.Index(account, ":"); i >= 0 {
+		issuer, account = account[:i], strings.TrimSpace(account[i+1:])
+	}
+	if q.Get("issuer") != "" {
+		issuer = q.Get("issuer")
+	}
+	return k, issuer, account, nil
+}
+
+// Remaining returns time left until the code of t changes.
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period / time.Second)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// Step returns number of the time step containing t.
+func (k Key) Step(t time.Time) int64 {
+	return t.Unix() / int64(k.Period/time.Second)
+}
+
+// At returns code of the time step containing t.
+func (k Key) At(t time.Time) (string, error) {
+	return k.Code(k.Step(t))
+}
+
+// Code returns RFC 4226 code of the counter.
+func (k Key) Code(counter int64) (string, error) {
+	var h func() hash.Hash
+	switch strings.ToUpper(k.Algorithm) {
+	case "", "SHA1":
+		h = sha1.New
+	case "SHA256":
+		h = sha256.New
+	case "SHA512":
+		h = sha512.New
+	default:
+		return "", ErrAlgorithm
+	}
+	if k.Digits < 6 || k.Digits > 10 {
+		return "", ErrDigits
+	}
+	if len(k.Secret) == 0 || k.Period < time.Second {
+		return "", ErrSecret
+	}
+	mac := hmac.New(h, k.Secret)
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod), nil
+}
+
+// Validate checks code against time steps around t, skew is the number of accepted steps before and after.
+// Matched step is returned so the caller can reject a code used twice.
+func (k Key) Validate(code string, t time.Time, skew int) (int64, bool) {
+	current := k.Step(t)
+	for i := -int64(skew); i <= int64(skew); i++ {
+		expected, err := k.Code(current + i)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return current + i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,95 @@
+package otp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey_At(t *testing.T) {
+	// test vectors of RFC 6238 appendix B
+	tests := []struct {
+		algorithm string
+		secret    string
+		unix      int64
+		want      string
+	}{
+		{algorithm: "SHA1", secret: "12345678901234567890", unix: 59, want: "94287082"},
+		{algorithm: "SHA1", secret: "12345678901234567890", unix: 1111111109, want: "07081804"},
+		{algorithm: "SHA256", secret: "12345678901234567890123456789012", unix: 1234567890, want: "91819424"},
+		{algorithm: "SHA512", secret: "1234567890123456789012345678901234567890123456789012345678901234", unix: 20000000000, want: "47863826"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm+tt.want, func(t *testing.T) {
+			k := Key{Secret: []byte(tt.secret), Algorithm: tt.algorithm, Digits: 8, Period: DefaultPeriod}
+			code, err := k.At(time.Unix(tt.unix, 0))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, code)
+		})
+	}
+}
+
+func TestKey_Validate(t *testing.T) {
+	k, err := NewKey()
+	require.NoError(t, err)
+	now := time.Now()
+	previous, err := k.At(now.Add(-DefaultPeriod))
+	require.NoError(t, err)
+	step, ok := k.Validate(previous, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, k.Step(now)-1, step)
+	_, ok = k.Validate(previous, now, 0)
+	assert.False(t, ok)
+	_, ok = k.Validate("000000x", now, 1)
+	assert.False(t, ok)
+}
+
+func TestKey_URI(t *testing.T) {
+	k := Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA1", Digits: 6, Period: DefaultPeriod}
+	assert.Equal(t, "otpauth://totp/GophKeeper:alice?algorithm=SHA1&digits=6&issuer=GophKeeper&period=30&secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", k.URI("GophKeeper", "alice"))
+	secret, err := DecodeSecret("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	assert.NoError(t, err)
+	assert.Equal(t, k.Secret, secret)
+	_, err = DecodeSecret("not base32!")
+	assert.ErrorIs(t, err, ErrSecret)
+}
+
+func TestParseURI(t *testing.T) {
+	k, issuer, account, err := ParseURI("otpauth://totp/ACME%20Co:john@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=sha256&digits=8&period=60")
+	require.NoError(t, err)
+	assert.Equal(t, Key{Secret: []byte("12345678901234567890"), Algorithm: "SHA256", Digits: 8, Period: time.Minute}, k)
+	assert.Equal(t, "ACME Co", issuer)
+	assert.Equal(t, "john@example.com", account)
+
+	// parameters default to values of authenticator apps, issuer parameter has priority over the label
+	k, issuer, account, err = ParseURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQ&issuer=GophKeeper")
+	require.NoError(t, err)
+	assert.Equal(t, Key{Secret: []byte("1234567890"), Algorithm: "SHA1", Digits: DefaultDigits, Period: DefaultPeriod}, k)
+	assert.Equal(t, "GophKeeper", issuer)
+	assert.Equal(t, "alice", account)
+
+	_, _, _, err = ParseURI("otpauth://hotp/alice?secret=GEZDGNBVGY3TQOJQ&counter=1")
+	assert.ErrorIs(t, err, ErrURI)
+	_, _, _, err = ParseURI("otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQ&algorithm=MD5")
+	assert.ErrorIs(t, err, ErrAlgorithm)
+	_, _, _, err = ParseURI("otpauth://totp/alice")
+	assert.ErrorIs(t, err, ErrSecret)
+}
+
+func TestKey_Remaining(t *testing.T) {
+	k := Key{Period: DefaultPeriod}
+	assert.Equal(t, 30*time.Second, k.Remaining(time.Unix(60, 0)))
+	assert.Equal(t, time.Second, k.Remaining(time.Unix(89, 500)))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+	assert.Len(t, codes[0], 11)
+	assert.NotEqual(t, codes[0], codes[1])
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))))
+}
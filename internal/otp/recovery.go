@@ -0,0 +1,34 @@
+// Package otp implements RFC 4226 HOTP and RFC 6238 TOTP one-time passwords.
+package otp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// recoveryLen - random bytes in a recovery code, 50 bits shown as 10 base32 characters
+const recoveryLen = 10
+
+// NewRecoveryCodes generates n one-time recovery codes in "xxxxx-xxxxx" format.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		raw := make([]byte, recoveryLen)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(b32.EncodeToString(raw))[:recoveryLen]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns hash of the code under which it is saved.
+// Codes are random, so fast hash is enough, case, spaces and dashes are ignored.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
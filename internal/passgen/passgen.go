@@ -0,0 +1,202 @@
+// Package passgen generates random passwords and Diceware-style passphrases and reports their entropy.
+package passgen
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Character classes of passwords
+const (
+	Lower   = "abcdefghijklmnopqrstuvwxyz"
+	Upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	Digits  = "0123456789"
+	Symbols = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+	// Ambiguous - characters which are easy to confuse when a password is typed from screen
+	Ambiguous = "Il1|O0o"
+)
+
+// Limits of generated values
+const (
+	MaxLength = 1024
+	MaxWords  = 64
+)
+
+// Module errors
+var (
+	ErrLength  = errors.New("password is too short for its character classes or longer than 1024")
+	ErrClasses = errors.New("no character classes in password policy")
+	ErrWords   = errors.New("passphrase must have from 1 to 64 words")
+)
+
+//go:embed words.txt
+var wordList string
+
+// words - dictionary of passphrases, 2048 words give 11 bits each
+var words = strings.Fields(wordList)
+
+// Policy - rules of a random password, every enabled class is present in it
+type Policy struct {
+	Length  int
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+	// ExcludeAmbiguous - leave out characters from Ambiguous
+	ExcludeAmbiguous bool
+}
+
+// DefaultPolicy returns policy of 20 characters of all classes.
+func DefaultPolicy() Policy {
+	return Policy{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true}
+}
+
+// classes - enabled character classes without excluded characters
+func (p Policy) classes() []string {
+	var classes []string
+	for _, c := range []struct {
+		on    bool
+		chars string
+	}{{p.Lower, Lower}, {p.Upper, Upper}, {p.Digits, Digits}, {p.Symbols, Symbols}} {
+		if !c.on {
+			continue
+		}
+		if p.ExcludeAmbiguous {
+			c.chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(Ambiguous, r) {
+					return -1
+				}
+				return r
+			}, c.chars)
+		}
+		classes = append(classes, c.chars)
+	}
+	return classes
+}
+
+// validate - returns enabled classes if the policy can be satisfied
+func (p Policy) validate() ([]string, error) {
+	classes := p.classes()
+	if len(classes) == 0 {
+		return nil, ErrClasses
+	}
+	if p.Length < len(classes) || p.Length > MaxLength {
+		return nil, ErrLength
+	}
+	return classes, nil
+}
+
+// Generate returns random password of the policy.
+// Passwords missing a class are drawn again, so every valid password is equally likely.
+func (p Policy) Generate() (string, error) {
+	classes, err := p.validate()
+	if err != nil {
+		return "", err
+	}
+	alphabet := strings.Join(classes, "")
+	password := make([]byte, p.Length)
+	for {
+		for i := range password {
+			n, err := randInt(len(alphabet))
+			if err != nil {
+				return "", err
+			}
+			password[i] = alphabet[n]
+		}
+		if hasAll(string(password), classes) {
+			return string(password), nil
+		}
+	}
+}
+
+// hasAll - whether the password has a character of every class
+func hasAll(password string, classes []string) bool {
+	for _, c := range classes {
+		if !strings.ContainsAny(password, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Entropy returns bits of entropy of passwords of the policy, 0 if the policy is invalid.
+// Passwords missing a class are counted out by inclusion-exclusion.
+func (p Policy) Entropy() float64 {
+	classes, err := p.validate()
+	if err != nil {
+		return 0
+	}
+	total := new(big.Int)
+	length := big.NewInt(int64(p.Length))
+	for subset := 0; subset < 1<<len(classes); subset++ {
+		size, excluded := 0, 0
+		for i, c := range classes {
+			if subset&(1<<i) != 0 {
+				excluded++
+				continue
+			}
+			size += len(c)
+		}
+		term := new(big.Int).Exp(big.NewInt(int64(size)), length, nil)
+		if excluded%2 == 1 {
+			total.Sub(total, term)
+		} else {
+			total.Add(total, term)
+		}
+	}
+	return log2(total)
+}
+
+// Passphrase - rules of a Diceware-style passphrase of words from the embedded list
+type Passphrase struct {
+	Words     int
+	Separator string
+}
+
+// Generate returns random passphrase.
+func (p Passphrase) Generate() (string, error) {
+	if p.Words < 1 || p.Words > MaxWords {
+		return "", ErrWords
+	}
+	phrase := make([]string, p.Words)
+	for i := range phrase {
+		n, err := randInt(len(words))
+		if err != nil {
+			return "", err
+		}
+		phrase[i] = words[n]
+	}
+	return strings.Join(phrase, p.Separator), nil
+}
+
+// Entropy returns bits of entropy of the passphrase, 0 if the word count is invalid.
+func (p Passphrase) Entropy() float64 {
+	if p.Words < 1 || p.Words > MaxWords {
+		return 0
+	}
+	return float64(p.Words) * math.Log2(float64(len(words)))
+}
+
+// randInt - uniform random number in [0, n)
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
+
+// log2 - binary logarithm of a positive big number
+func log2(n *big.Int) float64 {
+	if n.Sign() <= 0 {
+		return 0
+	}
+	mant := new(big.Float)
+	exp := new(big.Float).SetInt(n).MantExp(mant)
+	m, _ := mant.Float64()
+	return float64(exp) + math.Log2(m)
+}
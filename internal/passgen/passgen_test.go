@@ -0,0 +1,69 @@
+package passgen
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Generate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		err    error
+	}{
+		{name: "default", policy: DefaultPolicy()},
+		{name: "shortest", policy: Policy{Length: 4, Lower: true, Upper: true, Digits: true, Symbols: true}},
+		{name: "digits", policy: Policy{Length: 6, Digits: true}},
+		{name: "no ambiguous", policy: Policy{Length: 64, Lower: true, Upper: true, Digits: true, Symbols: true, ExcludeAmbiguous: true}},
+		{name: "too short", policy: Policy{Length: 3, Lower: true, Upper: true, Digits: true, Symbols: true}, err: ErrLength},
+		{name: "too long", policy: Policy{Length: MaxLength + 1, Lower: true}, err: ErrLength},
+		{name: "no classes", policy: Policy{Length: 20}, err: ErrClasses},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			password, err := tt.policy.Generate()
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				assert.Zero(t, tt.policy.Entropy())
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, password, tt.policy.Length)
+			assert.True(t, hasAll(password, tt.policy.classes()))
+			assert.Equal(t, tt.policy.Symbols, strings.ContainsAny(password, Symbols))
+			if tt.policy.ExcludeAmbiguous {
+				assert.False(t, strings.ContainsAny(password, Ambiguous))
+			}
+		})
+	}
+}
+
+func TestPolicy_Entropy(t *testing.T) {
+	assert.InDelta(t, math.Log2(10), Policy{Length: 1, Digits: true}.Entropy(), 1e-9)
+	// 36^2 passwords without 26^2 of letters only and 10^2 of digits only
+	assert.InDelta(t, math.Log2(520), Policy{Length: 2, Lower: true, Digits: true}.Entropy(), 1e-9)
+	p := DefaultPolicy()
+	assert.InDelta(t, 20*math.Log2(90), p.Entropy(), 1)
+	p.ExcludeAmbiguous = true
+	assert.Less(t, p.Entropy(), DefaultPolicy().Entropy())
+}
+
+func TestPassphrase(t *testing.T) {
+	require.Len(t, words, 2048)
+	phrase, err := Passphrase{Words: 6, Separator: "-"}.Generate()
+	require.NoError(t, err)
+	parts := strings.Split(phrase, "-")
+	assert.Len(t, parts, 6)
+	for _, w := range parts {
+		assert.Contains(t, words, w)
+	}
+	assert.InDelta(t, 66, Passphrase{Words: 6}.Entropy(), 1e-9)
+
+	_, err = Passphrase{Words: 0}.Generate()
+	assert.ErrorIs(t, err, ErrWords)
+	assert.Zero(t, Passphrase{Words: MaxWords + 1}.Entropy())
+}
@@ -0,0 +1,194 @@
+package records
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// metaPrefix - marks meta information holding serialized fields and tags, plain text is kept as is for old clients
+const metaPrefix = "gkmeta1:"
+
+// Meta information errors
+var (
+	ErrField = errors.New("field must be name=value")
+	ErrTag   = errors.New("tag must not be empty or contain spaces")
+)
+
+// ParseField parses "name=value" of the command line, type of the value is guessed:
+// integers are numbers, true and false are bools, RFC 3339 timestamps are times, http and https addresses are urls.
+func ParseField(s string) (string, datamodels.Field, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return "", datamodels.Field{}, ErrField
+	}
+	name, value := strings.TrimSpace(s[:i]), s[i+1:]
+	if name == "" {
+		return "", datamodels.Field{}, ErrField
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return name, datamodels.Field{Type: datamodels.FieldNumber, Value: value}, nil
+	}
+	if value == "true" || value == "false" {
+		return name, datamodels.Field{Type: datamodels.FieldBool, Value: value}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return name, datamodels.Field{Type: datamodels.FieldTime, Value: t.UTC().Format(time.RFC3339)}, nil
+	}
+	if u, err := url.Parse(value); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		return name, datamodels.Field{Type: datamodels.FieldURL, Value: value}, nil
+	}
+	return name, datamodels.Field{Type: datamodels.FieldText, Value: value}, nil
+}
+
+// NormalizeTags returns sorted tags without duplicates.
+func NormalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || strings.ContainsAny(tag, " \t\n") {
+			return nil, ErrTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// EncodeMeta serializes meta information text with fields and tags, text without fields and tags is returned as is.
+func EncodeMeta(text string, fields map[string]datamodels.Field, tags []string) (string, error) {
+	if len(fields) == 0 && len(tags) == 0 {
+		return text, nil
+	}
+	m := &pb.Metadata{Text: text, Fields: make(map[string]*pb.MetaValue, len(fields)), Tags: tags}
+	for name, f := range fields {
+		v, err := metaValue(f)
+		if err != nil {
+			return "", err
+		}
+		m.Fields[name] = v
+	}
+	raw, err := proto.Marshal(m)
+	if err != nil {
+		return "", ErrCorrupted
+	}
+	return metaPrefix + base64.RawStdEncoding.EncodeToString(raw), nil
+}
+
+// DecodeMeta returns text, fields and tags serialized by EncodeMeta, plain meta information is returned as text.
+func DecodeMeta(meta string) (string, map[string]datamodels.Field, []string, error) {
+	if !strings.HasPrefix(meta, metaPrefix) {
+		return meta, nil, nil, nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(meta, metaPrefix))
+	if err != nil {
+		return "", nil, nil, ErrCorrupted
+	}
+	var m pb.Metadata
+	if err = proto.Unmarshal(raw, &m); err != nil {
+		return "", nil, nil, ErrCorrupted
+	}
+	var fields map[string]datamodels.Field
+	if len(m.Fields) > 0 {
+		fields = make(map[string]datamodels.Field, len(m.Fields))
+	}
+	for name, v := range m.Fields {
+		fields[name] = field(v)
+	}
+	return m.Text, fields, m.Tags, nil
+}
+
+// metaValue - protobuf value of the field
+func metaValue(f datamodels.Field) (*pb.MetaValue, error) {
+	switch f.Type {
+	case datamodels.FieldText:
+		return &pb.MetaValue{Value: &pb.MetaValue_Text{Text: f.Value}}, nil
+	case datamodels.FieldURL:
+		return &pb.MetaValue{Value: &pb.MetaValue_Url{Url: f.Value}}, nil
+	case datamodels.FieldNumber:
+		n, err := strconv.ParseInt(f.Value, 10, 64)
+		if err != nil {
+			return nil, ErrField
+		}
+		return &pb.MetaValue{Value: &pb.MetaValue_Number{Number: n}}, nil
+	case datamodels.FieldBool:
+		b, err := strconv.ParseBool(f.Value)
+		if err != nil {
+			return nil, ErrField
+		}
+		return &pb.MetaValue{Value: &pb.MetaValue_Flag{Flag: b}}, nil
+	case datamodels.FieldTime:
+		t, err := time.Parse(time.RFC3339, f.Value)
+		if err != nil {
+			return nil, ErrField
+		}
+		return &pb.MetaValue{Value: &pb.MetaValue_Time{Time: timestamppb.New(t)}}, nil
+	}
+	return nil, ErrField
+}
+
+// field - field of the protobuf value, unknown values are kept as empty text
+func field(v *pb.MetaValue) datamodels.Field {
+	switch x := v.GetValue().(type) {
+	case *pb.MetaValue_Url:
+		return datamodels.Field{Type: datamodels.FieldURL, Value: x.Url}
+	case *pb.MetaValue_Number:
+		return datamodels.Field{Type: datamodels.FieldNumber, Value: strconv.FormatInt(x.Number, 10)}
+	case *pb.MetaValue_Flag:
+		return datamodels.Field{Type: datamodels.FieldBool, Value: strconv.FormatBool(x.Flag)}
+	case *pb.MetaValue_Time:
+		return datamodels.Field{Type: datamodels.FieldTime, Value: x.Time.AsTime().UTC().Format(time.RFC3339)}
+	}
+	return datamodels.Field{Type: datamodels.FieldText, Value: v.GetText()}
+}
+
+// MatchMeta reports whether the note has all the tags and all the fields with equal values.
+func MatchMeta(note datamodels.Data, tags []string, fields map[string]string) bool {
+	has := make(map[string]bool, len(note.Tags))
+	for _, tag := range note.Tags {
+		has[tag] = true
+	}
+	for _, tag := range tags {
+		if !has[tag] {
+			return false
+		}
+	}
+	for name, value := range fields {
+		f, ok := note.Fields[name]
+		if !ok || f.Value != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatMeta returns fields sorted by name and tags for display.
+func FormatMeta(note datamodels.Data) string {
+	names := make([]string, 0, len(note.Fields))
+	for name := range note.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, name+"="+note.Fields[name].Value)
+	}
+	if len(note.Tags) > 0 {
+		parts = append(parts, "tags: "+strings.Join(note.Tags, ","))
+	}
+	return strings.Join(parts, " ")
+}
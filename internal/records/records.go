@@ -0,0 +1,278 @@
+// Package records implements typed secrets which are stored end to end inside data of a note.
+package records
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gophkeeper/internal/otp"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Kinds of records, they match names of the add subcommands
+const (
+	KindLogin = "login"
+	KindNote  = "note"
+	KindCard  = "card"
+	KindFile  = "file"
+	KindOTP   = "otp"
+)
+
+// MaxBinarySize - largest file kept inside a record, larger files are uploaded as blobs
+const MaxBinarySize = 1 << 20
+
+// prefix - marks data of a note holding a serialized record, data without it was written as plain text by old clients
+const prefix = "gkrecord1:"
+
+// Module errors
+var (
+	ErrEmpty       = errors.New("record is empty")
+	ErrLogin       = errors.New("login or password required")
+	ErrCardNumber  = errors.New("card number must have 12 to 19 digits")
+	ErrCardLuhn    = errors.New("card number is invalid, Luhn check failed")
+	ErrCardExpiry  = errors.New("card expiry must be MM/YY")
+	ErrCardExpired = errors.New("card is expired")
+	ErrCardCVV     = errors.New("card CVV must have 3 or 4 digits")
+	ErrFileName    = errors.New("file name required")
+	ErrFileTooBig  = fmt.Errorf("file is larger than %d bytes", MaxBinarySize)
+	ErrBlob        = errors.New("file must have either content or blob with its size and SHA-256")
+	ErrCorrupted   = errors.New("record corrupted")
+	errUnknownKind = errors.New("unknown record type")
+)
+
+var (
+	digits = regexp.MustCompile(`^[0-9]+$`)
+	expiry = regexp.MustCompile(`^(0[1-9]|1[0-2])/[0-9]{2}$`)
+)
+
+// LoginPassword - record with credentials of a site
+func LoginPassword(login string, password string, url string) *pb.Record {
+	return &pb.Record{Payload: &pb.Record_LoginPassword{LoginPassword: &pb.LoginPassword{Login: login, Password: password, Url: url}}}
+}
+
+// Text - record with free text
+func Text(text string) *pb.Record {
+	return &pb.Record{Payload: &pb.Record_Text{Text: &pb.Text{Text: text}}}
+}
+
+// Card - record with a bank card, spaces and dashes are removed from the number
+func Card(number string, holder string, expiry string, cvv string, notes string) *pb.Record {
+	number = strings.NewReplacer(" ", "", "-", "").Replace(number)
+	return &pb.Record{Payload: &pb.Record_Card{Card: &pb.Card{Number: number, Holder: holder, Expiry: expiry, Cvv: cvv, Notes: notes}}}
+}
+
+// luhn - checks the last digit of the card number
+func luhn(number string) bool {
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// Expires returns the moment the card stops working, cards are valid through the last day of the expiry month.
+func Expires(c *pb.Card) (time.Time, error) {
+	if !expiry.MatchString(c.GetExpiry()) {
+		return time.Time{}, ErrCardExpiry
+	}
+	month, _ := strconv.Atoi(c.GetExpiry()[:2])
+	year, _ := strconv.Atoi(c.GetExpiry()[3:])
+	return time.Date(2000+year, time.Month(month)+1, 1, 0, 0, 0, 0, time.Local), nil
+}
+
+// CheckExpiry returns ErrCardExpired when the card doesn't work at now, it is checked only when a card is added.
+func CheckExpiry(c *pb.Card, now time.Time) error {
+	expires, err := Expires(c)
+	if err != nil {
+		return err
+	}
+	if !now.Before(expires) {
+		return ErrCardExpired
+	}
+	return nil
+}
+
+// MaskNumber - card number with all digits but the last four hidden
+func MaskNumber(number string) string {
+	if len(number) <= 4 {
+		return strings.Repeat("*", len(number))
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+// Binary - record with contents of a file
+func Binary(name string, content []byte) *pb.Record {
+	return &pb.Record{Payload: &pb.Record_Binary{Binary: &pb.Binary{Name: name, Content: content}}}
+}
+
+// OTP - record with TOTP secret of the account
+func OTP(issuer string, account string, key otp.Key) *pb.Record {
+	return &pb.Record{Payload: &pb.Record_Otp{Otp: &pb.OTP{
+		Issuer: issuer, Account: account, Secret: key.Secret, Algorithm: key.Algorithm, Digits: int32(key.Digits), Period: int32(key.Period / time.Second),
+	}}}
+}
+
+// OTPKey returns key of the OTP record to generate codes.
+func OTPKey(o *pb.OTP) otp.Key {
+	return otp.Key{Secret: o.GetSecret(), Algorithm: o.GetAlgorithm(), Digits: int(o.GetDigits()), Period: time.Duration(o.GetPeriod()) * time.Second}
+}
+
+// Blob - record with a file uploaded as a blob, blob has id, size and SHA-256 of the plain file
+func Blob(name string, blob *pb.Binary) *pb.Record {
+	return &pb.Record{Payload: &pb.Record_Binary{Binary: &pb.Binary{Name: name, BlobId: blob.GetBlobId(), Size: blob.GetSize(), Sha256: blob.GetSha256()}}}
+}
+
+// FileSize - size of the file in the record
+func FileSize(b *pb.Binary) int64 {
+	if b.GetBlobId() != "" {
+		return b.GetSize()
+	}
+	return int64(len(b.GetContent()))
+}
+
+// Kind returns kind of the record.
+func Kind(r *pb.Record) string {
+	switch r.GetPayload().(type) {
+	case *pb.Record_LoginPassword:
+		return KindLogin
+	case *pb.Record_Text:
+		return KindNote
+	case *pb.Record_Card:
+		return KindCard
+	case *pb.Record_Binary:
+		return KindFile
+	case *pb.Record_Otp:
+		return KindOTP
+	}
+	return ""
+}
+
+// Validate checks fields required by the record type.
+func Validate(r *pb.Record) error {
+	switch p := r.GetPayload().(type) {
+	case *pb.Record_LoginPassword:
+		if p.LoginPassword.GetLogin() == "" && p.LoginPassword.GetPassword() == "" {
+			return ErrLogin
+		}
+	case *pb.Record_Text:
+		if p.Text.GetText() == "" {
+			return ErrEmpty
+		}
+	case *pb.Record_Card:
+		c := p.Card
+		if len(c.GetNumber()) < 12 || len(c.GetNumber()) > 19 || !digits.MatchString(c.GetNumber()) {
+			return ErrCardNumber
+		}
+		if !luhn(c.GetNumber()) {
+			return ErrCardLuhn
+		}
+		if !expiry.MatchString(c.GetExpiry()) {
+			return ErrCardExpiry
+		}
+		if c.GetCvv() != "" && (len(c.GetCvv()) < 3 || len(c.GetCvv()) > 4 || !digits.MatchString(c.GetCvv())) {
+			return ErrCardCVV
+		}
+	case *pb.Record_Binary:
+		if p.Binary.GetName() == "" {
+			return ErrFileName
+		}
+		if len(p.Binary.GetContent()) > MaxBinarySize {
+			return ErrFileTooBig
+		}
+		if p.Binary.GetBlobId() != "" && (len(p.Binary.GetContent()) > 0 || len(p.Binary.GetSha256()) != sha256.Size || p.Binary.GetSize() < 0) {
+			return ErrBlob
+		}
+	case *pb.Record_Otp:
+		// secret and parameters are checked by generating a code
+		if _, err := OTPKey(p.Otp).Code(0); err != nil {
+			return err
+		}
+	default:
+		return errUnknownKind
+	}
+	return nil
+}
+
+// Encode validates the record and serializes it into data of a note.
+func Encode(r *pb.Record) (string, error) {
+	if err := Validate(r); err != nil {
+		return "", err
+	}
+	raw, err := proto.Marshal(r)
+	if err != nil {
+		return "", ErrCorrupted
+	}
+	return prefix + base64.RawStdEncoding.EncodeToString(raw), nil
+}
+
+// Decode returns record serialized by Encode, plain data of old notes is returned as a text record.
+func Decode(data string) (*pb.Record, error) {
+	if !strings.HasPrefix(data, prefix) {
+		return Text(data), nil
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(data, prefix))
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	var r pb.Record
+	if err = proto.Unmarshal(raw, &r); err != nil {
+		return nil, ErrCorrupted
+	}
+	if Kind(&r) == "" {
+		return nil, errUnknownKind
+	}
+	return &r, nil
+}
+
+// Format returns record fields for display, card number, CVV and OTP secret are hidden unless reveal is set.
+func Format(r *pb.Record, reveal bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Type: %s\n", Kind(r))
+	switch p := r.GetPayload().(type) {
+	case *pb.Record_LoginPassword:
+		fmt.Fprintf(&b, "Login: %s\nPassword: %s", p.LoginPassword.GetLogin(), p.LoginPassword.GetPassword())
+		if p.LoginPassword.GetUrl() != "" {
+			fmt.Fprintf(&b, "\nURL: %s", p.LoginPassword.GetUrl())
+		}
+	case *pb.Record_Text:
+		b.WriteString(p.Text.GetText())
+	case *pb.Record_Card:
+		number, cvv := p.Card.GetNumber(), p.Card.GetCvv()
+		if !reveal {
+			number, cvv = MaskNumber(number), strings.Repeat("*", len(cvv))
+		}
+		fmt.Fprintf(&b, "Number: %s\nExpiry: %s", number, p.Card.GetExpiry())
+		if p.Card.GetHolder() != "" {
+			fmt.Fprintf(&b, "\nHolder: %s", p.Card.GetHolder())
+		}
+		if cvv != "" {
+			fmt.Fprintf(&b, "\nCVV: %s", cvv)
+		}
+		if p.Card.GetNotes() != "" {
+			fmt.Fprintf(&b, "\nNotes: %s", p.Card.GetNotes())
+		}
+	case *pb.Record_Binary:
+		fmt.Fprintf(&b, "File: %s\nSize: %d bytes", p.Binary.GetName(), FileSize(p.Binary))
+	case *pb.Record_Otp:
+		fmt.Fprintf(&b, "Issuer: %s\nAccount: %s\nAlgorithm: %s\nDigits: %d\nPeriod: %ds", p.Otp.GetIssuer(), p.Otp.GetAccount(), p.Otp.GetAlgorithm(), p.Otp.GetDigits(), p.Otp.GetPeriod())
+		if reveal {
+			fmt.Fprintf(&b, "\nSecret: %s", OTPKey(p.Otp).EncodedSecret())
+		}
+	}
+	return b.String()
+}
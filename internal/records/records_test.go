@@ -0,0 +1,130 @@
+package records
+
+import (
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/otp"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *pb.Record
+		err    error
+	}{
+		{name: "login", record: LoginPassword("user", "secret", "")},
+		{name: "empty login", record: LoginPassword("", "", "https://example.com"), err: ErrLogin},
+		{name: "note", record: Text("text")},
+		{name: "empty note", record: Text(""), err: ErrEmpty},
+		{name: "card", record: Card("4111 1111 1111 1111", "", "12/27", "123", "")},
+		{name: "card without cvv", record: Card("4111-1111-1111-1111", "", "01/30", "", "")},
+		{name: "short card number", record: Card("41111", "", "12/27", "123", ""), err: ErrCardNumber},
+		{name: "card number with letters", record: Card("4111abcd11111111", "", "12/27", "123", ""), err: ErrCardNumber},
+		{name: "card number with wrong check digit", record: Card("4111111111111112", "", "12/27", "123", ""), err: ErrCardLuhn},
+		{name: "wrong expiry", record: Card("4111111111111111", "", "13/27", "123", ""), err: ErrCardExpiry},
+		{name: "wrong cvv", record: Card("4111111111111111", "", "12/27", "12", ""), err: ErrCardCVV},
+		{name: "file", record: Binary("key.pem", []byte("key"))},
+		{name: "file without name", record: Binary("", []byte("key")), err: ErrFileName},
+		{name: "large file", record: Binary("big", make([]byte, MaxBinarySize+1)), err: ErrFileTooBig},
+		{name: "blob", record: Blob("big", &pb.Binary{BlobId: "id", Size: 10, Sha256: make([]byte, 32)})},
+		{name: "blob without hash", record: Blob("big", &pb.Binary{BlobId: "id", Size: 10}), err: ErrBlob},
+		{name: "otp", record: OTP("ACME", "bot", otp.Key{Secret: []byte("secret"), Algorithm: "SHA1", Digits: 6, Period: otp.DefaultPeriod})},
+		{name: "otp without secret", record: OTP("ACME", "bot", otp.Key{Algorithm: "SHA1", Digits: 6, Period: otp.DefaultPeriod}), err: otp.ErrSecret},
+		{name: "no payload", record: &pb.Record{}, err: errUnknownKind},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, Validate(tt.record))
+		})
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	card := Card("4111 1111 1111 1111", "IVAN IVANOV", "12/27", "123", "")
+	encoded, err := Encode(card)
+	require.NoError(t, err)
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(card, decoded))
+	assert.Equal(t, "4111111111111111", decoded.GetCard().GetNumber())
+	assert.Equal(t, "Type: card\nNumber: 4111111111111111\nExpiry: 12/27\nHolder: IVAN IVANOV\nCVV: 123", Format(decoded, true))
+	assert.Equal(t, "Type: card\nNumber: ************1111\nExpiry: 12/27\nHolder: IVAN IVANOV\nCVV: ***", Format(decoded, false))
+
+	// notes of old clients hold plain text
+	legacy, err := Decode("plain text")
+	require.NoError(t, err)
+	assert.Equal(t, KindNote, Kind(legacy))
+	assert.Equal(t, "plain text", legacy.GetText().GetText())
+
+	_, err = Decode(prefix + "!!!")
+	assert.Equal(t, ErrCorrupted, err)
+	_, err = Encode(Text(""))
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestOTP(t *testing.T) {
+	key, issuer, account, err := otp.ParseURI("otpauth://totp/ACME:bot?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8")
+	require.NoError(t, err)
+	encoded, err := Encode(OTP(issuer, account, key))
+	require.NoError(t, err)
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, KindOTP, Kind(decoded))
+	assert.Equal(t, key, OTPKey(decoded.GetOtp()))
+	assert.Equal(t, "Type: otp\nIssuer: ACME\nAccount: bot\nAlgorithm: SHA1\nDigits: 8\nPeriod: 30s", Format(decoded, false))
+}
+
+func TestMeta(t *testing.T) {
+	fields := make(map[string]datamodels.Field)
+	for _, f := range []string{"env=staging", "port=5432", "rotated=2026-01-02T03:04:05+03:00", "enabled=true", "url=https://db.example.com"} {
+		name, field, err := ParseField(f)
+		require.NoError(t, err)
+		fields[name] = field
+	}
+	assert.Equal(t, datamodels.Field{Type: datamodels.FieldText, Value: "staging"}, fields["env"])
+	assert.Equal(t, datamodels.Field{Type: datamodels.FieldNumber, Value: "5432"}, fields["port"])
+	assert.Equal(t, datamodels.Field{Type: datamodels.FieldTime, Value: "2026-01-02T00:04:05Z"}, fields["rotated"])
+	assert.Equal(t, datamodels.Field{Type: datamodels.FieldBool, Value: "true"}, fields["enabled"])
+	assert.Equal(t, datamodels.Field{Type: datamodels.FieldURL, Value: "https://db.example.com"}, fields["url"])
+	_, _, err := ParseField("=value")
+	assert.Equal(t, ErrField, err)
+
+	tags, err := NormalizeTags([]string{"prod", "db", "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "prod"}, tags)
+	_, err = NormalizeTags([]string{"two words"})
+	assert.Equal(t, ErrTag, err)
+
+	encoded, err := EncodeMeta("main database", fields, tags)
+	require.NoError(t, err)
+	text, decodedFields, decodedTags, err := DecodeMeta(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, "main database", text)
+	assert.Equal(t, fields, decodedFields)
+	assert.Equal(t, tags, decodedTags)
+
+	note := datamodels.Data{Fields: fields, Tags: tags}
+	assert.True(t, MatchMeta(note, []string{"prod"}, map[string]string{"env": "staging"}))
+	assert.False(t, MatchMeta(note, []string{"prod", "web"}, nil))
+	assert.False(t, MatchMeta(note, nil, map[string]string{"env": "prod"}))
+
+	// plain meta information of old notes has no fields
+	encoded, err = EncodeMeta("plain", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "plain", encoded)
+}
+
+func TestCheckExpiry(t *testing.T) {
+	card := Card("4111111111111111", "", "02/27", "", "").GetCard()
+	// card works through the last day of the expiry month
+	assert.NoError(t, CheckExpiry(card, time.Date(2027, time.February, 28, 23, 0, 0, 0, time.Local)))
+	assert.Equal(t, ErrCardExpired, CheckExpiry(card, time.Date(2027, time.March, 1, 0, 0, 0, 0, time.Local)))
+	assert.Equal(t, ErrCardExpiry, CheckExpiry(Card("4111111111111111", "", "2/27", "", "").GetCard(), time.Now()))
+}
@@ -26,6 +26,11 @@ func (u *UserSession) AddUser(login string, password string, id uint32) error {
 	return nil
 }
 
+// SetUser adds user or replaces existing one, used to apply updates of user info.
+func (u *UserSession) SetUser(login string, user datamodels.Login) {
+	u.users[login] = user
+}
+
 // GetUser retrieves a user from the session storage based on the login.
 // It returns the user and a boolean indicating if the user exists.
 func (u *UserSession) GetUser(login string) (datamodels.Login, bool) {
@@ -0,0 +1,83 @@
+// Package sessionstorage provides an implementation of SessionStorage for storing user session information.
+package sessionstorage
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// dbSessionStorage is an implementation of SessionStorage that stores sessions in the PostgreSQL sessions table.
+// Only SHA-256 of a token is saved, so a database dump can't be used to act as a user.
+// Tokens of different kinds share the table but are never accepted in place of each other.
+type dbSessionStorage struct {
+	db   *sql.DB
+	ttl  time.Duration
+	kind string
+}
+
+// NewDBSessionStorage creates SessionStorage shared by all servers using the database, sessions expire after ttl.
+func NewDBSessionStorage(db *sql.DB, ttl time.Duration) SessionStorage {
+	return &dbSessionStorage{db: db, ttl: ttl, kind: "session"}
+}
+
+// NewDBChallengeStorage creates storage of second factor challenges issued between password check and session creation.
+func NewDBChallengeStorage(db *sql.DB, ttl time.Duration) SessionStorage {
+	return &dbSessionStorage{db: db, ttl: ttl, kind: "challenge"}
+}
+
+// hashToken - returns key of the token in the sessions table
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// AddUser adds a new session to the sessions table.
+func (ds *dbSessionStorage) AddUser(user string, id uint32, device string) (time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ds.ttl)
+	_, err := ds.db.Exec("insert into sessions (token_hash, user_id, device, created_at, expires_at, kind) values ($1, $2, $3, $4, $5, $6);",
+		hashToken(user), id, device, now, expiresAt, ds.kind)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return expiresAt, nil
+}
+
+// GetUser retrieves the user ID of the session token.
+// Expired session is removed and ErrExpired is returned.
+func (ds *dbSessionStorage) GetUser(user string) (uint32, error) {
+	var id uint32
+	var expiresAt time.Time
+	err := ds.db.QueryRow("select user_id, expires_at from sessions where token_hash=$1 and kind=$2;", hashToken(user), ds.kind).Scan(&id, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if time.Now().After(expiresAt) {
+		_ = ds.DelUser(user)
+		return 0, ErrExpired
+	}
+	return id, nil
+}
+
+// DelUser removes the session token.
+func (ds *dbSessionStorage) DelUser(user string) error {
+	_, err := ds.db.Exec("delete from sessions where token_hash=$1 and kind=$2;", hashToken(user), ds.kind)
+	return err
+}
+
+// Cleanup removes expired sessions.
+func (ds *dbSessionStorage) Cleanup() error {
+	_, err := ds.db.Exec("delete from sessions where expires_at < $1 and kind=$2;", time.Now(), ds.kind)
+	return err
+}
+
+// DelUserSessions removes all sessions of the user except the token.
+func (ds *dbSessionStorage) DelUserSessions(id uint32, except string) error {
+	_, err := ds.db.Exec("delete from sessions where user_id=$1 and token_hash<>$2 and kind=$3;", id, hashToken(except), ds.kind)
+	return err
+}
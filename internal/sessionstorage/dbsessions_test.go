@@ -0,0 +1,121 @@
+package sessionstorage_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/sessionstorage"
+	"gophkeeper/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDB - database of TEST_DATABASE_DSN migrated by storage, migrations are read from the repository root
+func testDB(t *testing.T) *storage.DBStorage {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("../.."))
+	t.Cleanup(func() { os.Chdir(wd) })
+	dbs, err := storage.NewDBStorage(dsn, nil)
+	require.NoError(t, err)
+	return dbs
+}
+
+// testUser - registers a new user, sessions reference users
+func testUser(t *testing.T, dbs *storage.DBStorage) uint32 {
+	login := fmt.Sprintf("sessions%d", time.Now().UnixNano())
+	require.NoError(t, dbs.Auth(login, "password"))
+	id, err := dbs.UserID(login)
+	require.NoError(t, err)
+	return id
+}
+
+// token - unique token of the test run
+func token(name string) string {
+	return fmt.Sprintf("%s%d", name, time.Now().UnixNano())
+}
+
+func TestDBSessionStorage(t *testing.T) {
+	dbs := testDB(t)
+	db, id := dbs.DB(), testUser(t, dbs)
+	sessions := sessionstorage.NewDBSessionStorage(db, time.Hour)
+	laptop := token("laptop")
+	expiresAt, err := sessions.AddUser(laptop, id, "laptop")
+	require.NoError(t, err)
+	assert.True(t, expiresAt.After(time.Now()))
+	got, err := sessions.GetUser(laptop)
+	require.NoError(t, err)
+	assert.Equal(t, id, got)
+
+	// only SHA-256 of the token is saved
+	sum := sha256.Sum256([]byte(laptop))
+	var n int
+	require.NoError(t, db.QueryRow("select count(*) from sessions where token_hash=$1;", sum[:]).Scan(&n))
+	assert.Equal(t, 1, n)
+	require.NoError(t, db.QueryRow("select count(*) from sessions where token_hash=$1;", []byte(laptop)).Scan(&n))
+	assert.Zero(t, n)
+
+	// challenges share the table but aren't accepted as sessions
+	challenges := sessionstorage.NewDBChallengeStorage(db, time.Hour)
+	challenge := token("challenge")
+	_, err = challenges.AddUser(challenge, id, "laptop")
+	require.NoError(t, err)
+	_, err = sessions.GetUser(challenge)
+	assert.ErrorIs(t, err, sessionstorage.ErrNotFound)
+	_, err = challenges.GetUser(laptop)
+	assert.ErrorIs(t, err, sessionstorage.ErrNotFound)
+
+	// logout revokes the token
+	require.NoError(t, sessions.DelUser(laptop))
+	_, err = sessions.GetUser(laptop)
+	assert.ErrorIs(t, err, sessionstorage.ErrNotFound)
+}
+
+func TestDBSessionStorage_TTL(t *testing.T) {
+	dbs := testDB(t)
+	db, id := dbs.DB(), testUser(t, dbs)
+	sessions := sessionstorage.NewDBSessionStorage(db, -time.Second)
+	expired := token("expired")
+	_, err := sessions.AddUser(expired, id, "laptop")
+	require.NoError(t, err)
+	_, err = sessions.GetUser(expired)
+	assert.ErrorIs(t, err, sessionstorage.ErrExpired)
+	_, err = sessions.GetUser(expired)
+	assert.ErrorIs(t, err, sessionstorage.ErrNotFound)
+
+	cleaned := token("cleaned")
+	_, err = sessions.AddUser(cleaned, id, "laptop")
+	require.NoError(t, err)
+	require.NoError(t, sessions.Cleanup())
+	_, err = sessions.GetUser(cleaned)
+	assert.ErrorIs(t, err, sessionstorage.ErrNotFound)
+}
+
+func TestDBSessionStorage_DelUserSessions(t *testing.T) {
+	dbs := testDB(t)
+	db, id, other := dbs.DB(), testUser(t, dbs), testUser(t, dbs)
+	sessions := sessionstorage.NewDBSessionStorage(db, time.Hour)
+	tokens := map[string]string{}
+	for _, device := range []string{"laptop", "phone", "tablet"} {
+		tokens[device] = token(device)
+		_, err := sessions.AddUser(tokens[device], id, device)
+		require.NoError(t, err)
+	}
+	tokens["other"] = token("other")
+	_, err := sessions.AddUser(tokens["other"], other, "laptop")
+	require.NoError(t, err)
+
+	require.NoError(t, sessions.DelUserSessions(id, tokens["laptop"]))
+	for device, err := range map[string]error{"laptop": nil, "phone": sessionstorage.ErrNotFound, "tablet": sessionstorage.ErrNotFound, "other": nil} {
+		_, got := sessions.GetUser(tokens[device])
+		assert.ErrorIs(t, got, err, device)
+	}
+}
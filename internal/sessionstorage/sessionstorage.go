@@ -4,41 +4,113 @@ package sessionstorage
 import (
 	"errors"
 	"sync"
+	"time"
+)
+
+// DefaultTTL - lifetime of a session if it is not refreshed
+const DefaultTTL = time.Hour
+
+// Module errors
+var (
+	ErrNotFound = errors.New("user not found")
+	ErrExpired  = errors.New("session expired")
 )
 
 // SessionStorage defines the methods for managing user sessions.
 type SessionStorage interface {
-	AddUser(user string, id uint32) error
+	// AddUser saves session token of the user on the device and returns time when the session expires.
+	AddUser(user string, id uint32, device string) (time.Time, error)
 
 	GetUser(user string) (uint32, error)
+
+	// DelUser removes session token.
+	DelUser(user string) error
+
+	// Cleanup removes expired sessions.
+	Cleanup() error
+
+	// DelUserSessions removes all sessions of the user except the token.
+	DelUserSessions(id uint32, except string) error
+}
+
+// session - user id, device and expiration time of a token
+type session struct {
+	id        uint32
+	device    string
+	expiresAt time.Time
 }
 
 // authUsersStorage is an implementation of SessionStorage that stores user session data in memory.
 type authUsersStorage struct {
-	authUsers map[string]uint32
+	authUsers map[string]session
+	ttl       time.Duration
 	mutex     sync.RWMutex
 }
 
-// NewAuthUsersStorage creates a new instance of authUsersStorage.
+// NewAuthUsersStorage creates a new instance of authUsersStorage with DefaultTTL.
 func NewAuthUsersStorage() SessionStorage {
-	return &authUsersStorage{authUsers: make(map[string]uint32)}
+	return NewAuthUsersStorageTTL(DefaultTTL)
+}
+
+// NewAuthUsersStorageTTL creates a new instance of authUsersStorage, sessions expire after ttl.
+func NewAuthUsersStorageTTL(ttl time.Duration) SessionStorage {
+	return &authUsersStorage{authUsers: make(map[string]session), ttl: ttl}
 }
 
 // AddUser adds a new user to the session storage.
-func (us *authUsersStorage) AddUser(user string, id uint32) error {
+func (us *authUsersStorage) AddUser(user string, id uint32, device string) (time.Time, error) {
+	expiresAt := time.Now().Add(us.ttl)
 	us.mutex.Lock()
-	us.authUsers[user] = id
+	us.authUsers[user] = session{id: id, device: device, expiresAt: expiresAt}
 	us.mutex.Unlock()
-	return nil
+	return expiresAt, nil
 }
 
 // GetUser retrieves the user ID from the session storage based on the username.
+// Expired session is removed and ErrExpired is returned.
 func (us *authUsersStorage) GetUser(user string) (uint32, error) {
 	us.mutex.RLock()
-	id, ok := us.authUsers[user]
+	s, ok := us.authUsers[user]
 	us.mutex.RUnlock()
 	if !ok {
-		return 0, errors.New("user not found")
+		return 0, ErrNotFound
+	}
+	if time.Now().After(s.expiresAt) {
+		_ = us.DelUser(user)
+		return 0, ErrExpired
 	}
-	return id, nil
+	return s.id, nil
+}
+
+// DelUser removes the session token.
+func (us *authUsersStorage) DelUser(user string) error {
+	us.mutex.Lock()
+	delete(us.authUsers, user)
+	us.mutex.Unlock()
+	return nil
+}
+
+// Cleanup removes expired sessions.
+func (us *authUsersStorage) Cleanup() error {
+	now := time.Now()
+	us.mutex.Lock()
+	for token, s := range us.authUsers {
+		if now.After(s.expiresAt) {
+			delete(us.authUsers, token)
+		}
+	}
+	us.mutex.Unlock()
+	return nil
+}
+
+// DelUserSessions removes all sessions of the user except the token.
+func (us *authUsersStorage) DelUserSessions(id uint32, except string) error {
+	us.mutex.Lock()
+	for token, s := range us.authUsers {
+		if s.id == id && token != except {
+			delete(us.authUsers, token)
+		}
+	}
+	us.mutex.Unlock()
+	return nil
 }
@@ -3,6 +3,7 @@ package sessionstorage
 import (
 	"fmt"
 	"log"
+	"time"
 )
 
 func ExampleUserSession_AddUser() {
@@ -33,9 +34,9 @@ func ExampleUserSession_GetUser() {
 	//password
 	//0
 }
-func ExampleAuthUsersStorage_AddUser_GetUser() {
+func ExampleNewAuthUsersStorage() {
 	user := NewAuthUsersStorage()
-	err := user.AddUser("userToken", 0)
+	expiresAt, err := user.AddUser("userToken", 0, "laptop")
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -44,6 +45,59 @@ func ExampleAuthUsersStorage_AddUser_GetUser() {
 		log.Fatalln(err)
 	}
 	fmt.Println(id)
+	fmt.Println(expiresAt.After(time.Now()))
 	//Output:
 	//0
+	//true
+}
+func ExampleNewAuthUsersStorageTTL() {
+	user := NewAuthUsersStorageTTL(-time.Second)
+	_, err := user.AddUser("userToken", 0, "laptop")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	_, err = user.GetUser("userToken")
+	fmt.Println(err)
+	_, err = user.GetUser("userToken")
+	fmt.Println(err)
+	//Output:
+	//session expired
+	//user not found
+}
+func ExampleSessionStorage_Cleanup() {
+	user := NewAuthUsersStorageTTL(-time.Second)
+	_, err := user.AddUser("userToken", 0, "laptop")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err = user.Cleanup(); err != nil {
+		log.Fatalln(err)
+	}
+	_, err = user.GetUser("userToken")
+	fmt.Println(err)
+	//Output:
+	//user not found
+}
+func ExampleSessionStorage_DelUserSessions() {
+	user := NewAuthUsersStorage()
+	for _, token := range []string{"laptop", "phone", "tablet"} {
+		if _, err := user.AddUser(token, 1, token); err != nil {
+			log.Fatalln(err)
+		}
+	}
+	if _, err := user.AddUser("other", 2, "laptop"); err != nil {
+		log.Fatalln(err)
+	}
+	if err := user.DelUserSessions(1, "laptop"); err != nil {
+		log.Fatalln(err)
+	}
+	for _, token := range []string{"laptop", "phone", "tablet", "other"} {
+		_, err := user.GetUser(token)
+		fmt.Println(token, err)
+	}
+	//Output:
+	//laptop <nil>
+	//phone user not found
+	//tablet user not found
+	//other <nil>
 }
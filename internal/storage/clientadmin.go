@@ -0,0 +1,36 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// lockoutError - converts ResourceExhausted status into error telling when login can be retried, other errors are returned as is
+func lockoutError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		return err
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.RetryInfo); ok {
+			return fmt.Errorf("too many failed attempts, retry in %s", info.RetryDelay.AsDuration().Round(time.Second))
+		}
+	}
+	return fmt.Errorf("too many failed attempts, retry later")
+}
+
+// UnlockAccount forgets failed login attempts of the login and of the address, adminToken is configured on the server.
+func (ms *MemoryStorage) UnlockAccount(adminToken string, login string, ip string) error {
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("admin-token", adminToken))
+	_, err := Client.UnlockAccount(ctx, &pb.UnlockRequest{Login: login, Ip: ip})
+	return err
+}
@@ -0,0 +1,15 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	pb "gophkeeper/proto"
+)
+
+// ListAuditEvents returns page of the logged in user audit log and before id of the next page.
+func (ms *MemoryStorage) ListAuditEvents(beforeID int64, limit int32) ([]*pb.AuditEvent, int64, error) {
+	resp, err := Client.ListAuditEvents(authContext(), &pb.ListAuditEventsRequest{BeforeId: beforeID, Limit: limit})
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Events, resp.NextBeforeId, nil
+}
@@ -0,0 +1,241 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// blobChunkSize - size of plain file parts sealed separately
+	blobChunkSize = 64 << 10
+	// uploadAttempts - how many times a broken upload is resumed before it is given up
+	uploadAttempts = 3
+)
+
+// sealChunk - seals part of a file with its number, so chunks can't be reordered
+func sealChunk(c utils.Cipher, seq uint64, data []byte) ([]byte, error) {
+	plain := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(plain, seq)
+	return c.Seal(append(plain, data...))
+}
+
+// openChunk - opens chunk sealed by sealChunk and checks its number
+func openChunk(c utils.Cipher, seq uint64, sealed []byte) ([]byte, error) {
+	plain, err := c.Open(sealed)
+	if err != nil || len(plain) < 8 || binary.BigEndian.Uint64(plain) != seq {
+		return nil, ErrCorrupted
+	}
+	return plain[8:], nil
+}
+
+// UploadError - upload was interrupted, it can be continued by ResumeUpload with the id
+type UploadError struct {
+	ID  string
+	Err error
+}
+
+func (e *UploadError) Error() string {
+	return fmt.Sprintf("upload %s interrupted: %v", e.ID, e.Err)
+}
+
+func (e *UploadError) Unwrap() error {
+	return e.Err
+}
+
+// UploadBlob seals contents of r chunk by chunk and streams them to server as a new blob of the note dataID,
+// the server erases it when the note is purged. It returns blob reference with size and SHA-256 of the plain contents which is kept in the record.
+func (ms *MemoryStorage) UploadBlob(userID uint32, dataID string, r io.ReadSeeker) (*pb.Binary, error) {
+	id, err := utils.GenerateToken()
+	if err != nil {
+		return nil, ErrInternal
+	}
+	return ms.ResumeUpload(userID, id, dataID, r)
+}
+
+// ResumeUpload streams contents of r as the blob id starting from the first chunk the server hasn't received.
+// Broken stream is resumed a few times, then UploadError with the id is returned, so the upload can be continued later.
+// Chunks received earlier aren't sent again, so r must not change between attempts: the record keeps SHA-256 of r,
+// and a file changed in the middle of the upload fails the check on download.
+func (ms *MemoryStorage) ResumeUpload(userID uint32, id, dataID string, r io.ReadSeeker) (*pb.Binary, error) {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		err = sendChunks(c, id, dataID, r)
+		if err == nil {
+			break
+		}
+		if attempt == uploadAttempts || !resumable(err) {
+			return nil, &UploadError{ID: id, Err: err}
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	return &pb.Binary{BlobId: id, Size: size, Sha256: h.Sum(nil)}, nil
+}
+
+// resumable - errors of a broken stream after which upload can be continued
+func resumable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Aborted, codes.FailedPrecondition, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// uploadStatus - how much of the upload the server has received, unknown upload hasn't started yet
+func uploadStatus(id string) (*pb.UploadStatusResponse, error) {
+	resp, err := Client.UploadStatus(authContext(), &pb.BlobRequest{BlobId: id})
+	if status.Code(err) == codes.NotFound {
+		return &pb.UploadStatusResponse{BlobId: id}, nil
+	}
+	return resp, err
+}
+
+// sendChunks - seals and streams chunks of r starting from the upload offset, the last message with amount of chunks
+// and size of the blob completes it. Local errors cancel the stream, so the server keeps the upload unfinished.
+func sendChunks(c utils.Cipher, id, dataID string, r io.ReadSeeker) error {
+	st, err := uploadStatus(id)
+	if err != nil || st.Complete {
+		return err
+	}
+	seq, size := st.Chunks, st.Size
+	if _, err = r.Seek(seq*blobChunkSize, io.SeekStart); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(authContext())
+	defer cancel()
+	stream, err := Client.UploadBlob(ctx)
+	if err != nil {
+		return err
+	}
+	// send returns error of the stream, Send reports only io.EOF when the server has closed it
+	send := func(msg *pb.BlobChunk) error {
+		if err := stream.Send(msg); err != io.EOF {
+			return err
+		}
+		_, err := stream.CloseAndRecv()
+		return err
+	}
+	if err = send(&pb.BlobChunk{BlobId: id, DataId: dataID}); err != nil {
+		return err
+	}
+	buf := make([]byte, blobChunkSize)
+	for {
+		n, errRead := io.ReadFull(r, buf)
+		if errRead != nil && errRead != io.EOF && errRead != io.ErrUnexpectedEOF {
+			return errRead
+		}
+		if n == 0 {
+			break
+		}
+		sealed, err := sealChunk(c, uint64(seq), buf[:n])
+		if err != nil {
+			return ErrInternal
+		}
+		sum := sha256.Sum256(sealed)
+		if err = send(&pb.BlobChunk{Data: sealed, Sha256: sum[:], Seq: seq}); err != nil {
+			return err
+		}
+		seq++
+		size += int64(len(sealed))
+		if errRead != nil {
+			break
+		}
+	}
+	if err = send(&pb.BlobChunk{Last: true, Chunks: seq, Size: size}); err != nil {
+		return err
+	}
+	_, err = stream.CloseAndRecv()
+	return err
+}
+
+// replacedBlob - blob of the local file record which note replaces, empty if there is none or note keeps the same blob
+func (ms *MemoryStorage) replacedBlob(c utils.Cipher, note datamodels.Data) string {
+	prev, ok := ms.localMem[datamodels.UniqueData{DataID: note.DataID, UserID: note.UserID}]
+	if !ok {
+		return ""
+	}
+	prev, err := openNote(c, prev)
+	if err != nil {
+		return ""
+	}
+	old := blobOf(prev.Data)
+	if old == blobOf(note.Data) {
+		return ""
+	}
+	return old
+}
+
+// blobOf - id of the blob referenced by the encoded record, empty for other records
+func blobOf(data string) string {
+	r, err := records.Decode(data)
+	if err != nil {
+		return ""
+	}
+	return r.GetBinary().GetBlobId()
+}
+
+// DownloadBlob streams blob of the record from server, opens its chunks and writes them to w.
+// Size and SHA-256 of the plain contents are checked against the record, so server can't change or cut the file.
+func (ms *MemoryStorage) DownloadBlob(userID uint32, ref *pb.Binary, w io.Writer) error {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return err
+	}
+	stream, err := Client.DownloadBlob(authContext(), &pb.BlobRequest{BlobId: ref.BlobId})
+	if err != nil {
+		return err
+	}
+	plainHash := sha256.New()
+	var size int64
+	for seq := uint64(0); ; {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(chunk.Data) == 0 {
+			continue
+		}
+		data, err := openChunk(c, seq, chunk.Data)
+		if err != nil {
+			return err
+		}
+		seq++
+		size += int64(len(data))
+		plainHash.Write(data)
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+	}
+	if size != ref.Size || !bytes.Equal(plainHash.Sum(nil), ref.Sha256) {
+		return errors.New("downloaded file doesn't match the record")
+	}
+	return nil
+}
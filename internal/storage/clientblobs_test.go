@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplacedBlob(t *testing.T) {
+	c, err := openVault("password", base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef")), nil)
+	require.NoError(t, err)
+	ms := &MemoryStorage{localMem: make(map[datamodels.UniqueData]datamodels.Data)}
+	file := func(blobID string) datamodels.Data {
+		data, err := records.Encode(records.Blob("report.pdf", &pb.Binary{BlobId: blobID, Size: 1, Sha256: make([]byte, 32)}))
+		require.NoError(t, err)
+		return datamodels.Data{UserID: 1, DataID: "report", Data: data}
+	}
+	note, err := records.Encode(records.Text("text"))
+	require.NoError(t, err)
+
+	// a new record replaces nothing
+	assert.Empty(t, ms.replacedBlob(c, file("first")))
+	require.NoError(t, ms.putLocal(c, file("first")))
+	assert.Empty(t, ms.replacedBlob(c, file("first")))
+	assert.Equal(t, "first", ms.replacedBlob(c, file("second")))
+	assert.Equal(t, "first", ms.replacedBlob(c, datamodels.Data{UserID: 1, DataID: "report", Data: note}))
+	// notes of other users and other data ids are separate
+	assert.Empty(t, ms.replacedBlob(c, datamodels.Data{UserID: 2, DataID: "report", Data: note}))
+
+	require.NoError(t, ms.putLocal(c, datamodels.Data{UserID: 1, DataID: "report", Data: note}))
+	assert.Empty(t, ms.replacedBlob(c, file("second")))
+}
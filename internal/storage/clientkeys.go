@@ -0,0 +1,311 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// newLocalUser - creates new local user with fresh key derivation salt, vault file is written when the vault is unlocked
+func newLocalUser(login string, password string, id uint32) (datamodels.Login, error) {
+	salt, err := utils.NewSalt()
+	if err != nil {
+		return datamodels.Login{}, ErrInternal
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return datamodels.Login{}, ErrInternal
+	}
+	user := datamodels.Login{ID: id, Password: hash, Salt: base64.RawStdEncoding.EncodeToString(salt)}
+	Users.SetUser(login, user)
+	return user, nil
+}
+
+// upgradeLocalHash - checks password against the local hash and replaces legacy MD5 hash with Argon2id one.
+// trusted means that the password was already accepted by server.
+func upgradeLocalHash(login string, password string, user datamodels.Login, trusted bool) (datamodels.Login, error) {
+	ok, rehash := utils.VerifyPassword(password, user.Password)
+	if !ok && !trusted {
+		return datamodels.Login{}, ErrWrongPassword
+	}
+	if ok && !rehash {
+		return user, nil
+	}
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return datamodels.Login{}, ErrInternal
+	}
+	user.Password = hash
+	Users.SetUser(login, user)
+	return user, nil
+}
+
+// cipherFromSalt - derives vault cipher from the master password and base64 salt
+func cipherFromSalt(password string, salt string) (utils.Cipher, error) {
+	key, err := keyFromSalt(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	return c, nil
+}
+
+// keyFromSalt - derives raw vault key from the master password and base64 salt
+func keyFromSalt(password string, salt string) ([]byte, error) {
+	decoded, err := base64.RawStdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return utils.DeriveKey(password, decoded), nil
+}
+
+// vault - vault cipher of the user which also opens data sealed with keys of previous master passwords.
+// Seal always uses the current key.
+type vault struct {
+	utils.Cipher
+	key []byte
+	// history - raw keys of previous master passwords, newest first
+	history [][]byte
+	old     []utils.Cipher
+}
+
+// newVault - creates vault for the current key and keys of previous passwords
+func newVault(key []byte, history ...[]byte) (*vault, error) {
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	v := &vault{Cipher: c, key: key}
+	for _, h := range history {
+		old, err := utils.NewCipher(h)
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		v.history = append(v.history, h)
+		v.old = append(v.old, old)
+	}
+	return v, nil
+}
+
+// Open decrypts envelope sealed with the current key or with any previous key.
+func (v *vault) Open(envelope []byte) ([]byte, error) {
+	opened, err := v.Cipher.Open(envelope)
+	if err == nil {
+		return opened, nil
+	}
+	for _, old := range v.old {
+		if opened, errOld := old.Open(envelope); errOld == nil {
+			return opened, nil
+		}
+	}
+	return nil, err
+}
+
+// next - vault of the new key which keeps the current key and its history
+func (v *vault) next(key []byte) (*vault, error) {
+	return newVault(key, append([][]byte{v.key}, v.history...)...)
+}
+
+// sealHistory - seals previous keys with the current key, only the owner of the password can open them
+func (v *vault) sealHistory() ([][]byte, error) {
+	sealed := make([][]byte, 0, len(v.history))
+	for _, h := range v.history {
+		envelope, err := v.Cipher.Seal(h)
+		if err != nil {
+			return nil, ErrInternal
+		}
+		sealed = append(sealed, envelope)
+	}
+	return sealed, nil
+}
+
+// openVault - derives vault key from the password and opens key history sealed with it
+func openVault(password string, salt string, sealed [][]byte) (*vault, error) {
+	key, err := keyFromSalt(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	history := make([][]byte, 0, len(sealed))
+	for _, envelope := range sealed {
+		h, err := c.Open(envelope)
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		history = append(history, h)
+	}
+	return newVault(key, history...)
+}
+
+// encodeHistory - base64 form of sealed key history saved in the users file
+func encodeHistory(sealed [][]byte) []string {
+	var encoded []string
+	for _, envelope := range sealed {
+		encoded = append(encoded, base64.RawStdEncoding.EncodeToString(envelope))
+	}
+	return encoded
+}
+
+// decodeHistory - decodes key history saved by encodeHistory
+func decodeHistory(encoded []string) ([][]byte, error) {
+	var sealed [][]byte
+	for _, v := range encoded {
+		envelope, err := base64.RawStdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		sealed = append(sealed, envelope)
+	}
+	return sealed, nil
+}
+
+// unlock - derives vault key of the user from the master password.
+// Salt and key history received from server are shared by all devices of the user, so end-to-end envelopes can be opened everywhere.
+// Notes sealed with the legacy client key, with the previous local salt or with keys of previous passwords are moved to the derived key.
+func (ms *MemoryStorage) unlock(login string, password string, user datamodels.Login, serverSalt []byte, serverHistory [][]byte) error {
+	salt := user.Salt
+	history := user.KeyHistory
+	if len(serverSalt) > 0 {
+		salt = base64.RawStdEncoding.EncodeToString(serverSalt)
+		history = encodeHistory(serverHistory)
+	}
+	if salt == "" {
+		newSalt, err := utils.NewSalt()
+		if err != nil {
+			return ErrInternal
+		}
+		salt = base64.RawStdEncoding.EncodeToString(newSalt)
+	}
+	sealed, err := decodeHistory(history)
+	if err != nil {
+		return err
+	}
+	v, err := openVault(password, salt, sealed)
+	if err != nil {
+		return err
+	}
+	fallbacks := []utils.Cipher{ms.legacy, v}
+	if user.Salt != "" && user.Salt != salt {
+		if previous, err := cipherFromSalt(password, user.Salt); err == nil {
+			fallbacks = append(fallbacks, previous)
+		}
+	}
+	if err = ms.migrate(user.ID, v.Cipher, fallbacks...); err != nil {
+		return err
+	}
+	user.Salt, user.KeyHistory = salt, history
+	Users.SetUser(login, user)
+	ms.keys[user.ID] = v
+	ms.logins[user.ID] = login
+	// user and notes moved to the new key are written to the vault file at once
+	return ms.persist(user.ID)
+}
+
+// migrate - re-encrypts in memory notes of the user which can be opened only by one of the fallback ciphers
+func (ms *MemoryStorage) migrate(userID uint32, c utils.Cipher, fallbacks ...utils.Cipher) error {
+	for k, v := range ms.localMem {
+		if k.UserID != userID {
+			continue
+		}
+		if _, _, err := openPair(c, v.Data, v.Metadata); err == nil {
+			continue
+		}
+		for _, fallback := range fallbacks {
+			data, meta, err := openPair(fallback, v.Data, v.Metadata)
+			if err != nil {
+				continue
+			}
+			v.Data, v.Metadata, err = sealPair(c, data, meta)
+			if err != nil {
+				return ErrInternal
+			}
+			ms.localMem[k] = v
+			break
+		}
+	}
+	return nil
+}
+
+// cipherFor - returns vault cipher of the user unlocked by Login
+func (ms *MemoryStorage) cipherFor(userID uint32) (utils.Cipher, error) {
+	c, ok := ms.keys[userID]
+	if !ok {
+		return nil, ErrLocked
+	}
+	return c, nil
+}
+
+// keyIDString - id of the vault key sent to server with end-to-end envelopes
+func keyIDString(c utils.Cipher) string {
+	return fmt.Sprintf("%08x", c.KeyID())
+}
+
+// toServer - seals plain note into end-to-end envelopes, server gets no plaintext
+func toServer(c utils.Cipher, note datamodels.Data) (*pb.Data, error) {
+	dataBlob, err := c.Seal([]byte(note.Data))
+	if err != nil {
+		return nil, ErrInternal
+	}
+	meta, err := packMeta(note)
+	if err != nil {
+		return nil, err
+	}
+	metaBlob, err := c.Seal([]byte(meta))
+	if err != nil {
+		return nil, ErrInternal
+	}
+	return &pb.Data{
+		DataId:    note.DataID,
+		DataBlob:  dataBlob,
+		MetaBlob:  metaBlob,
+		KeyId:     keyIDString(c),
+		Deleted:   note.Deleted,
+		ChangedAt: timestamppb.New(note.ChangedAt),
+	}, nil
+}
+
+// fromServer - returns plain note received from server.
+// Notes without key id were written by old clients and come decrypted by the server.
+// Envelopes sealed with keys of previous passwords are opened by the vault as well.
+func fromServer(c utils.Cipher, userID uint32, v *pb.Data) (datamodels.Data, error) {
+	note := datamodels.Data{UserID: userID, DataID: v.DataId, Data: v.Data, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
+	if v.KeyId == "" {
+		return unpackMeta(note)
+	}
+	data, err := c.Open(v.DataBlob)
+	if err != nil {
+		return datamodels.Data{}, ErrCorrupted
+	}
+	meta, err := c.Open(v.MetaBlob)
+	if err != nil {
+		return datamodels.Data{}, ErrCorrupted
+	}
+	note.Data, note.Metadata = string(data), string(meta)
+	return unpackMeta(note)
+}
+
+// migrateNote - returns plain note received from server and its envelope sealed with the current key which replaces
+// the note on server, nil envelope means that the note is already sealed with the current key
+func migrateNote(c utils.Cipher, userID uint32, v *pb.Data) (datamodels.Data, *pb.Data, error) {
+	note, err := fromServer(c, userID, v)
+	if err != nil || v.KeyId == keyIDString(c) {
+		return note, nil, err
+	}
+	sealed, err := toServer(c, note)
+	if err != nil {
+		return datamodels.Data{}, nil, err
+	}
+	return note, sealed, nil
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVaultHistory(t *testing.T) {
+	salt := base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef"))
+	first, err := openVault("old", salt, nil)
+	require.NoError(t, err)
+	sealed, err := utils.SealString(first, "note")
+	require.NoError(t, err)
+
+	next, err := first.next(utils.DeriveKey("new", []byte("fedcba9876543210")))
+	require.NoError(t, err)
+	history, err := next.sealHistory()
+	require.NoError(t, err)
+
+	// another device derives the new key and opens old notes through the history
+	other, err := openVault("new", base64.RawStdEncoding.EncodeToString([]byte("fedcba9876543210")), history)
+	require.NoError(t, err)
+	opened, err := utils.OpenString(other, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "note", opened)
+	assert.Equal(t, keyIDString(next), keyIDString(other))
+	_, err = utils.OpenString(other.Cipher, sealed)
+	assert.Error(t, err)
+
+	_, err = openVault("wrong", base64.RawStdEncoding.EncodeToString([]byte("fedcba9876543210")), history)
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestServerEnvelopes(t *testing.T) {
+	v, err := openVault("password", base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef")), nil)
+	require.NoError(t, err)
+	changed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	note := datamodels.Data{UserID: 1, DataID: "mail", Data: "secret", Metadata: "work", ChangedAt: changed}
+
+	sealed, err := toServer(v, note)
+	require.NoError(t, err)
+	assert.Equal(t, keyIDString(v), sealed.KeyId)
+	assert.Empty(t, sealed.Data)
+	assert.False(t, bytes.Contains(sealed.DataBlob, []byte("secret")))
+	opened, sealedAgain, err := migrateNote(v, 1, sealed)
+	require.NoError(t, err)
+	assert.Nil(t, sealedAgain)
+	assert.Equal(t, note, opened)
+
+	// envelopes of another key or changed on the way aren't opened
+	other, err := openVault("other", base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef")), nil)
+	require.NoError(t, err)
+	_, err = fromServer(other, 1, sealed)
+	assert.ErrorIs(t, err, ErrCorrupted)
+	sealed.DataBlob[len(sealed.DataBlob)-1] ^= 1
+	_, err = fromServer(v, 1, sealed)
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	// rows of old clients come decrypted by the server and are sent back sealed
+	legacy := &pb.Data{DataId: "mail", Data: "secret", MetaInfo: "work", ChangedAt: sealed.ChangedAt}
+	opened, migrated, err := migrateNote(v, 1, legacy)
+	require.NoError(t, err)
+	assert.Equal(t, note, opened)
+	require.NotNil(t, migrated)
+	assert.Equal(t, keyIDString(v), migrated.KeyId)
+	assert.Empty(t, migrated.Data)
+	assert.Empty(t, migrated.MetaInfo)
+	reopened, err := fromServer(v, 1, migrated)
+	require.NoError(t, err)
+	assert.Equal(t, note, reopened)
+
+	// envelopes of a previous password are opened through the history and sealed with the current key
+	next, err := v.next(utils.DeriveKey("new", []byte("fedcba9876543210")))
+	require.NoError(t, err)
+	old, err := toServer(v, note)
+	require.NoError(t, err)
+	opened, migrated, err = migrateNote(next, 1, old)
+	require.NoError(t, err)
+	assert.Equal(t, note, opened)
+	require.NotNil(t, migrated)
+	assert.Equal(t, keyIDString(next), migrated.KeyId)
+}
@@ -0,0 +1,33 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/records"
+	"gophkeeper/internal/utils"
+)
+
+// packMeta - meta information of the note with fields and tags serialized into one string to be sealed,
+// string of a note without fields and tags is returned as is, so packing twice changes nothing
+func packMeta(note datamodels.Data) (string, error) {
+	return records.EncodeMeta(note.Metadata, note.Fields, note.Tags)
+}
+
+// unpackMeta - restores fields and tags of the note from its opened meta information
+func unpackMeta(note datamodels.Data) (datamodels.Data, error) {
+	var err error
+	note.Metadata, note.Fields, note.Tags, err = records.DecodeMeta(note.Metadata)
+	if err != nil {
+		return datamodels.Data{}, ErrCorrupted
+	}
+	return note, nil
+}
+
+// openNote - decrypts note kept locally with its structured meta information
+func openNote(c utils.Cipher, note datamodels.Data) (datamodels.Data, error) {
+	var err error
+	if note.Data, note.Metadata, err = openPair(c, note.Data, note.Metadata); err != nil {
+		return datamodels.Data{}, err
+	}
+	return unpackMeta(note)
+}
@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoteMeta(t *testing.T) {
+	v, err := openVault("password", base64.RawStdEncoding.EncodeToString([]byte("0123456789abcdef")), nil)
+	require.NoError(t, err)
+	note := datamodels.Data{
+		UserID: 1, DataID: "db", Data: "secret", Metadata: "main database",
+		Fields: map[string]datamodels.Field{"env": {Type: datamodels.FieldText, Value: "staging"}},
+		Tags:   []string{"prod"},
+	}
+	sealed, err := toServer(v, note)
+	require.NoError(t, err)
+	// server sees neither fields nor tags
+	assert.False(t, bytes.Contains(sealed.MetaBlob, []byte("staging")))
+	assert.Empty(t, sealed.MetaInfo)
+
+	opened, err := fromServer(v, 1, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, note.Metadata, opened.Metadata)
+	assert.Equal(t, note.Fields, opened.Fields)
+	assert.Equal(t, note.Tags, opened.Tags)
+
+	// meta information of old notes stays plain text
+	note.Fields, note.Tags = nil, nil
+	sealed, err = toServer(v, note)
+	require.NoError(t, err)
+	opened, err = fromServer(v, 1, sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "main database", opened.Metadata)
+	assert.Nil(t, opened.Fields)
+}
@@ -0,0 +1,76 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+	pb "gophkeeper/proto"
+)
+
+// ChangePassword replaces master password of the user and re-encrypts the vault with the key of the new password.
+// Every step can be interrupted: server keeps keys of previous passwords sealed with the new key,
+// so the vault file and notes left under an old key are moved to the new key on next Login or Sync.
+func (ms *MemoryStorage) ChangePassword(login string, oldPassword string, newPassword string) error {
+	id, err := ms.Login(login, oldPassword)
+	if err != nil {
+		return err
+	}
+	if len(md.Get("userid")) == 0 {
+		return errors.New("server is unavailable, password can be changed only online")
+	}
+	current, ok := ms.keys[id]
+	if !ok {
+		return ErrLocked
+	}
+	salt, err := utils.NewSalt()
+	if err != nil {
+		return ErrInternal
+	}
+	next, err := current.next(utils.DeriveKey(newPassword, salt))
+	if err != nil {
+		return err
+	}
+	history, err := next.sealHistory()
+	if err != nil {
+		return err
+	}
+	_, err = Client.ChangePassword(authContext(), &pb.ChangePasswordRequest{OldPassword: oldPassword, NewPassword: newPassword, VaultSalt: salt, KeyHistory: history})
+	if err != nil {
+		return lockoutError(err)
+	}
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return ErrInternal
+	}
+	Users.SetUser(login, datamodels.Login{ID: id, Password: hash, Salt: base64.RawStdEncoding.EncodeToString(salt), KeyHistory: encodeHistory(history)})
+	ms.keys[id] = next
+	ms.reencrypt(id, next)
+	// user with the new salt and re-encrypted notes replace the vault file at once
+	if err = ms.persist(id); err != nil {
+		return err
+	}
+	// notes on server are re-sealed by Sync as their key id differs from the new one
+	if _, err = ms.Sync(id); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reencrypt - seals in memory notes of the user with the current key of the vault, notes which can't be opened are left as is
+func (ms *MemoryStorage) reencrypt(userID uint32, v *vault) {
+	for k, note := range ms.localMem {
+		if k.UserID != userID {
+			continue
+		}
+		data, meta, err := openPair(v, note.Data, note.Metadata)
+		if err != nil {
+			continue
+		}
+		if note.Data, note.Metadata, err = sealPair(v, data, meta); err == nil {
+			ms.localMem[k] = note
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"gophkeeper/internal/datamodels"
+	pb "gophkeeper/proto"
+)
+
+// ListRevisions returns previous versions of the note kept by the server, newest first.
+func (ms *MemoryStorage) ListRevisions(dataID string) ([]*pb.Revision, error) {
+	resp, err := Client.ListRevisions(authContext(), &pb.GetDataRequest{DataId: dataID})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Revisions, nil
+}
+
+// GetRevision returns decrypted previous version of the note, versions sealed with keys of previous passwords are opened by the vault.
+func (ms *MemoryStorage) GetRevision(userID uint32, dataID string, revision int64) (datamodels.Data, error) {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	resp, err := Client.GetRevision(authContext(), &pb.RevisionRequest{DataId: dataID, Revision: revision})
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	return fromServer(c, userID, resp.Data)
+}
+
+// RestoreRevision makes previous version the current version of the note on server and brings it into the local vault by Sync.
+func (ms *MemoryStorage) RestoreRevision(userID uint32, dataID string, revision int64) error {
+	if _, err := ms.cipherFor(userID); err != nil {
+		return err
+	}
+	if _, err := Client.RestoreRevision(authContext(), &pb.RevisionRequest{DataId: dataID, Revision: revision}); err != nil {
+		return err
+	}
+	_, err := ms.Sync(userID)
+	return err
+}
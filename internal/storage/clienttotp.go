@@ -0,0 +1,51 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"errors"
+
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// SecondFactor - asks user for TOTP or recovery code when server requires it, set by the client application
+var SecondFactor func() (string, error)
+
+// verifySecondFactor - sends code for the Login challenge, session token is returned in header
+func verifySecondFactor(challenge string, header *metadata.MD) (*pb.AuthLoginResponse, error) {
+	if SecondFactor == nil {
+		return nil, errors.New("two-factor authentication code required")
+	}
+	code, err := SecondFactor()
+	if err != nil {
+		return nil, err
+	}
+	return Client.VerifySecondFactor(outgoingContext(), &pb.SecondFactorRequest{Challenge: challenge, Code: code}, grpc.Header(header))
+}
+
+// EnrollTOTP starts enabling two-factor authentication of the logged in user and returns base32 TOTP secret.
+func (ms *MemoryStorage) EnrollTOTP() (string, error) {
+	resp, err := Client.EnrollTOTP(authContext(), &emptypb.Empty{})
+	if err != nil {
+		return "", err
+	}
+	return resp.Secret, nil
+}
+
+// ConfirmTOTP enables two-factor authentication if the code matches enrolled secret and returns recovery codes.
+func (ms *MemoryStorage) ConfirmTOTP(code string) ([]string, error) {
+	resp, err := Client.ConfirmTOTP(authContext(), &pb.TOTPCodeRequest{Code: code})
+	if err != nil {
+		return nil, err
+	}
+	return resp.RecoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication off, code is TOTP or recovery code.
+func (ms *MemoryStorage) DisableTOTP(code string) error {
+	_, err := Client.DisableTOTP(authContext(), &pb.TOTPCodeRequest{Code: code})
+	return err
+}
@@ -0,0 +1,103 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	pb "gophkeeper/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// ListDeleted returns decrypted notes in the trash sorted by data id,
+// notes deleted here which are not synced yet are listed too.
+func (ms *MemoryStorage) ListDeleted(userID uint32) ([]datamodels.Data, error) {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.ListDeleted(authContext(), &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	trash := make(map[string]datamodels.Data, len(resp.Data))
+	for _, v := range resp.Data {
+		note, err := fromServer(c, userID, v)
+		if err != nil {
+			return nil, err
+		}
+		trash[note.DataID] = note
+	}
+	for k, note := range ms.localMem {
+		if k.UserID != userID || !note.Deleted {
+			continue
+		}
+		if v, ok := trash[k.DataID]; ok && !v.ChangedAt.Before(note.ChangedAt) {
+			continue
+		}
+		if note, err = openNote(c, note); err != nil {
+			return nil, err
+		}
+		trash[k.DataID] = note
+	}
+	list := make([]datamodels.Data, 0, len(trash))
+	for _, note := range trash {
+		list = append(list, note)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DataID < list[j].DataID })
+	return list, nil
+}
+
+// Undelete restores the note from the trash on server and brings it back into the local vault by Sync.
+// A note whose deletion didn't reach server yet is restored locally.
+func (ms *MemoryStorage) Undelete(userID uint32, dataID string) error {
+	if _, err := ms.cipherFor(userID); err != nil {
+		return err
+	}
+	_, err := Client.Undelete(authContext(), &pb.GetDataRequest{DataId: dataID})
+	if status.Code(err) == codes.NotFound {
+		key := datamodels.UniqueData{DataID: dataID, UserID: userID}
+		note, ok := ms.localMem[key]
+		if !ok || !note.Deleted {
+			return ErrNotFound
+		}
+		note.Deleted = false
+		note.ChangedAt = time.Now()
+		ms.localMem[key] = note
+		return ms.persist(userID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = ms.Sync(userID)
+	return err
+}
+
+// Purge erases the note from the trash, empty dataID erases the whole trash.
+// Local copies are dropped by Sync, other devices drop theirs on their next sync.
+func (ms *MemoryStorage) Purge(userID uint32, dataID string) error {
+	if _, err := ms.cipherFor(userID); err != nil {
+		return err
+	}
+	_, err := Client.Purge(authContext(), &pb.PurgeRequest{DataId: dataID, All: dataID == ""})
+	local := false
+	for k, note := range ms.localMem {
+		if k.UserID == userID && note.Deleted && (dataID == "" || k.DataID == dataID) {
+			delete(ms.localMem, k)
+			local = true
+		}
+	}
+	if status.Code(err) == codes.NotFound && local {
+		// deletion didn't reach server, so there was nothing to erase there
+		return ms.persist(userID)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = ms.Sync(userID)
+	return err
+}
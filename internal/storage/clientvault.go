@@ -0,0 +1,74 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+
+	"gophkeeper/internal/datamodels"
+	files "gophkeeper/internal/storage/filereaders"
+	"gophkeeper/internal/utils"
+)
+
+// loadLocal - opens vault file of the login and loads its user and notes, nothing is loaded if the file doesn't exist.
+// When the password was changed on another device, the file is opened with a key from the key history received from server.
+func (ms *MemoryStorage) loadLocal(login string, password string, serverSalt []byte, serverHistory [][]byte) error {
+	path := files.VaultPath(vaultDir, login)
+	header, err := files.ReadHeader(path)
+	if err == files.ErrNoVault {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keys := [][]byte{utils.DeriveKeyParams(password, header.KDF.Salt, header.KDF.KDFParams)}
+	if len(serverSalt) > 0 && !bytes.Equal(serverSalt, header.KDF.Salt) {
+		if v, err := openVault(password, base64.RawStdEncoding.EncodeToString(serverSalt), serverHistory); err == nil {
+			keys = append(keys, v.history...)
+		}
+	}
+	var content files.Vault
+	for _, key := range keys {
+		content, err = files.ReadVault(path, key)
+		if err != files.ErrVaultKey {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	u := content.User
+	Users.SetUser(login, datamodels.Login{ID: u.ID, Password: u.Password, Salt: u.Salt, KeyHistory: u.KeyHistory})
+	for _, note := range content.Data {
+		ms.localMem[datamodels.UniqueData{DataID: note.DataID, UserID: note.UserID}] = note
+	}
+	return nil
+}
+
+// persist - writes user and notes of the unlocked vault to the vault file, lines of old clients are removed after that
+func (ms *MemoryStorage) persist(userID uint32) error {
+	v, ok := ms.keys[userID]
+	login, okLogin := ms.logins[userID]
+	if !ok || !okLogin {
+		return ErrLocked
+	}
+	user, _ := Users.GetUser(login)
+	salt, err := base64.RawStdEncoding.DecodeString(user.Salt)
+	if err != nil {
+		return ErrCorrupted
+	}
+	content := files.Vault{User: datamodels.Auth{ID: user.ID, Login: login, Password: user.Password, Salt: user.Salt, KeyHistory: user.KeyHistory}}
+	for k, note := range ms.localMem {
+		if k.UserID == userID {
+			content.Data = append(content.Data, note)
+		}
+	}
+	if err = files.WriteVault(files.VaultPath(vaultDir, login), files.NewKDF(salt), v.key, content); err != nil {
+		return errors.New("err writing vault file")
+	}
+	if err = files.DropLegacyUser(login, userID); err != nil {
+		return errors.New("err removing user from legacy files")
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"gophkeeper/internal/utils"
+)
+
+// sealPair - encrypts data and meta information of a single note
+func sealPair(c utils.Cipher, data string, meta string) (string, string, error) {
+	sealedData, err := utils.SealString(c, data)
+	if err != nil {
+		return "", "", err
+	}
+	sealedMeta, err := utils.SealString(c, meta)
+	if err != nil {
+		return "", "", err
+	}
+	return sealedData, sealedMeta, nil
+}
+
+// openPair - decrypts data and meta information of a single note, returns ErrCorrupted if any of them was tampered
+func openPair(c utils.Cipher, data string, meta string) (string, string, error) {
+	openedData, err := utils.OpenString(c, data)
+	if err != nil {
+		return "", "", ErrCorrupted
+	}
+	openedMeta, err := utils.OpenString(c, meta)
+	if err != nil {
+		return "", "", ErrCorrupted
+	}
+	return openedData, openedMeta, nil
+}
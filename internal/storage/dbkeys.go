@@ -0,0 +1,325 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"gophkeeper/internal/utils"
+)
+
+// rotationBatch - amount of notes re-encrypted between progress saves
+const rotationBatch = 100
+
+// dataKey - unwrapped data key of a user
+type dataKey struct {
+	masterID uint32
+	cipher   utils.Cipher
+}
+
+// rotationTable - table of server encrypted notes moved to the active master key, progress is the key_rotation column of its last row id
+type rotationTable struct {
+	name     string
+	filter   string
+	progress string
+}
+
+// rotationTables - notes are rotated first, then their previous versions
+var rotationTables = []rotationTable{
+	{name: "keeper", filter: " and purged=false", progress: "last_row_id"},
+	{name: "keeper_revisions", progress: "last_revision_id"},
+}
+
+// rotationRow - server encrypted note processed by RotateKeys
+type rotationRow struct {
+	id        int64
+	userID    uint32
+	data      string
+	meta      string
+	dataKeyID sql.NullInt64
+	changedAt time.Time
+}
+
+// userKey returns id and cipher of the user data key wrapped by the active master key, the key is created on first use.
+func (dbs *DBStorage) userKey(userID uint32) (int64, utils.Cipher, error) {
+	var id int64
+	err := dbs.db.QueryRow("select id from data_keys where user_id=$1 and master_key_id=$2 order by id desc limit 1;", userID, dbs.ring.ActiveID()).Scan(&id)
+	if err == nil {
+		k, err := dbs.dataKey(id)
+		if err != nil {
+			return 0, nil, err
+		}
+		return id, k.cipher, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, nil, ErrInternal
+	}
+	key := make([]byte, 32)
+	if _, err = rand.Read(key); err != nil {
+		return 0, nil, ErrInternal
+	}
+	masterID, wrapped, err := dbs.ring.Wrap(key)
+	if err != nil {
+		return 0, nil, ErrInternal
+	}
+	err = dbs.db.QueryRow("insert into data_keys (user_id, master_key_id, wrapped_key) values ($1, $2, $3) returning id;", userID, masterID, wrapped).Scan(&id)
+	if err != nil {
+		return 0, nil, ErrInternal
+	}
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return 0, nil, ErrInternal
+	}
+	dbs.keysMutex.Lock()
+	dbs.dataKeys[id] = dataKey{masterID: masterID, cipher: c}
+	dbs.keysMutex.Unlock()
+	return id, c, nil
+}
+
+// dataKey - unwraps data key with the id, unwrapped keys are cached
+func (dbs *DBStorage) dataKey(id int64) (dataKey, error) {
+	dbs.keysMutex.RLock()
+	k, ok := dbs.dataKeys[id]
+	dbs.keysMutex.RUnlock()
+	if ok {
+		return k, nil
+	}
+	var masterID uint32
+	var wrapped []byte
+	err := dbs.db.QueryRow("select master_key_id, wrapped_key from data_keys where id=$1;", id).Scan(&masterID, &wrapped)
+	if err != nil {
+		return dataKey{}, ErrInternal
+	}
+	k, err = dbs.unwrapKey(masterID, wrapped)
+	if err != nil {
+		return dataKey{}, err
+	}
+	dbs.keysMutex.Lock()
+	dbs.dataKeys[id] = k
+	dbs.keysMutex.Unlock()
+	return k, nil
+}
+
+// unwrapKey - opens data key wrapped by the master key with the id
+func (dbs *DBStorage) unwrapKey(masterID uint32, wrapped []byte) (dataKey, error) {
+	key, err := dbs.ring.Unwrap(masterID, wrapped)
+	if err != nil {
+		return dataKey{}, ErrCorrupted
+	}
+	c, err := utils.NewCipher(key)
+	if err != nil {
+		return dataKey{}, ErrCorrupted
+	}
+	return dataKey{masterID: masterID, cipher: c}, nil
+}
+
+// noteCipher - returns cipher of server encrypted note, notes without data key were written with the legacy server secret
+func (dbs *DBStorage) noteCipher(dataKeyID sql.NullInt64) (utils.Cipher, error) {
+	if !dataKeyID.Valid {
+		return dbs.legacy, nil
+	}
+	k, err := dbs.dataKey(dataKeyID.Int64)
+	if err != nil {
+		return nil, err
+	}
+	return k.cipher, nil
+}
+
+// RotateKeys re-encrypts server encrypted notes, their revisions and TOTP secrets with data keys wrapped by the active master key.
+// Progress is saved after every batch, so an interrupted job continues from the last processed row.
+func (dbs *DBStorage) RotateKeys(ctx context.Context) error {
+	active := dbs.ring.ActiveID()
+	_, err := dbs.db.ExecContext(ctx, "insert into key_rotation (master_key_id) values ($1) on conflict do nothing;", active)
+	if err != nil {
+		return err
+	}
+	var finished bool
+	err = dbs.db.QueryRowContext(ctx, "select finished from key_rotation where master_key_id=$1;", active).Scan(&finished)
+	if err != nil || finished {
+		return err
+	}
+	for _, table := range rotationTables {
+		if err = dbs.rotateTable(ctx, table); err != nil {
+			return err
+		}
+	}
+	if err = dbs.rotateTOTP(ctx); err != nil {
+		return err
+	}
+	_, err = dbs.db.ExecContext(ctx, "update key_rotation set finished=true where master_key_id=$1;", active)
+	if err != nil {
+		return err
+	}
+	// data keys wrapped by old master keys are not needed when no note, revision or TOTP secret uses them
+	_, err = dbs.db.ExecContext(ctx, `delete from data_keys d where d.master_key_id <> $1 and not exists (select 1 from keeper k where k.data_key_id = d.id)
+		and not exists (select 1 from keeper_revisions r where r.data_key_id = d.id)
+		and not exists (select 1 from totp t where t.data_key_id = d.id);`, active)
+	return err
+}
+
+// rotateTable - moves rows of the table to the active master key in batches starting after the saved progress
+func (dbs *DBStorage) rotateTable(ctx context.Context, table rotationTable) error {
+	active := dbs.ring.ActiveID()
+	var last int64
+	err := dbs.db.QueryRowContext(ctx, "select "+table.progress+" from key_rotation where master_key_id=$1;", active).Scan(&last)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := dbs.rotationBatch(ctx, table, last)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, row := range batch {
+			if err = dbs.rotateNote(ctx, table, row); err != nil {
+				log.Printf("key rotation: %s row %d skipped: %v", table.name, row.id, err)
+			}
+			last = row.id
+		}
+		_, err = dbs.db.ExecContext(ctx, "update key_rotation set "+table.progress+"=$2 where master_key_id=$1;", active, last)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rotationBatch - selects next server encrypted rows of the table after the row id
+func (dbs *DBStorage) rotationBatch(ctx context.Context, table rotationTable, after int64) ([]rotationRow, error) {
+	rows, err := dbs.db.QueryContext(ctx, "select id, user_id, data_info, meta_info, data_key_id, changed_at from "+table.name+" where id > $1 and key_id = ''"+table.filter+" order by id limit $2;", after, rotationBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch []rotationRow
+	for rows.Next() {
+		var row rotationRow
+		if err = rows.Scan(&row.id, &row.userID, &row.data, &row.meta, &row.dataKeyID, &row.changedAt); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// rewrapPair - moves data and meta information of a note from one data key to another
+func rewrapPair(from, to utils.Cipher, data, meta string) (string, string, error) {
+	data, meta, err := openPair(from, data, meta)
+	if err != nil {
+		return "", "", err
+	}
+	return sealPair(to, data, meta)
+}
+
+// rotateNote - moves row to the user data key of the active master key, note changed meanwhile is left untouched
+func (dbs *DBStorage) rotateNote(ctx context.Context, table rotationTable, row rotationRow) error {
+	if row.dataKeyID.Valid {
+		k, err := dbs.dataKey(row.dataKeyID.Int64)
+		if err != nil {
+			return err
+		}
+		if k.masterID == dbs.ring.ActiveID() {
+			return nil
+		}
+	}
+	old, err := dbs.noteCipher(row.dataKeyID)
+	if err != nil {
+		return err
+	}
+	id, c, err := dbs.userKey(row.userID)
+	if err != nil {
+		return err
+	}
+	data, meta, err := rewrapPair(old, c, row.data, row.meta)
+	if err != nil {
+		return err
+	}
+	_, err = dbs.db.ExecContext(ctx, "update "+table.name+" set data_info=$1, meta_info=$2, data_key_id=$3 where id=$4 and data_key_id is not distinct from $5 and changed_at=$6;",
+		data, meta, id, row.id, row.dataKeyID, row.changedAt)
+	return err
+}
+
+// totpRow - TOTP secret processed by RotateKeys
+type totpRow struct {
+	userID    uint32
+	secret    []byte
+	dataKeyID int64
+}
+
+// rotateTOTP - moves TOTP secrets to the active master key in batches of users starting after the saved progress
+func (dbs *DBStorage) rotateTOTP(ctx context.Context) error {
+	active := dbs.ring.ActiveID()
+	var last uint32
+	err := dbs.db.QueryRowContext(ctx, "select last_totp_user from key_rotation where master_key_id=$1;", active).Scan(&last)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := dbs.totpBatch(ctx, last)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, row := range batch {
+			if err = dbs.rotateSecret(ctx, row); err != nil {
+				log.Printf("key rotation: totp of user %d skipped: %v", row.userID, err)
+			}
+			last = row.userID
+		}
+		_, err = dbs.db.ExecContext(ctx, "update key_rotation set last_totp_user=$2 where master_key_id=$1;", active, last)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// totpBatch - selects next TOTP secrets after the user id
+func (dbs *DBStorage) totpBatch(ctx context.Context, after uint32) ([]totpRow, error) {
+	rows, err := dbs.db.QueryContext(ctx, "select user_id, secret, data_key_id from totp where user_id > $1 order by user_id limit $2;", after, rotationBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var batch []totpRow
+	for rows.Next() {
+		var row totpRow
+		if err = rows.Scan(&row.userID, &row.secret, &row.dataKeyID); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// rotateSecret - moves TOTP secret to the user data key of the active master key, secret replaced meanwhile is left untouched
+func (dbs *DBStorage) rotateSecret(ctx context.Context, row totpRow) error {
+	k, err := dbs.dataKey(row.dataKeyID)
+	if err != nil {
+		return err
+	}
+	if k.masterID == dbs.ring.ActiveID() {
+		return nil
+	}
+	secret, err := k.cipher.Open(row.secret)
+	if err != nil {
+		return ErrCorrupted
+	}
+	id, c, err := dbs.userKey(row.userID)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.Seal(secret)
+	if err != nil {
+		return ErrInternal
+	}
+	_, err = dbs.db.ExecContext(ctx, "update totp set secret=$1, data_key_id=$2 where user_id=$3 and data_key_id=$4 and secret=$5;", sealed, id, row.userID, row.dataKeyID, row.secret)
+	return err
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"testing"
+
+	"gophkeeper/internal/keyring"
+	"gophkeeper/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStorage - storage without database which wraps data keys with the keyring
+func testStorage(t *testing.T, keys map[uint32][]byte) *DBStorage {
+	var ring *keyring.Keyring
+	var err error
+	if keys != nil {
+		ring, err = keyring.New(keys)
+		require.NoError(t, err)
+	}
+	ring, err = withLegacyKey(ring)
+	require.NoError(t, err)
+	legacy, err := utils.NewCipher(dbSecret)
+	require.NoError(t, err)
+	return &DBStorage{ring: ring, legacy: legacy, dataKeys: make(map[int64]dataKey)}
+}
+
+// wrapNewKey - new data key wrapped by the active master key of the storage
+func wrapNewKey(t *testing.T, dbs *DBStorage) (uint32, []byte, utils.Cipher) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	masterID, wrapped, err := dbs.ring.Wrap(key)
+	require.NoError(t, err)
+	c, err := utils.NewCipher(key)
+	require.NoError(t, err)
+	return masterID, wrapped, c
+}
+
+func TestRewrap(t *testing.T) {
+	oldKey, newKey := []byte("old master key 0123456789abcdef!"), []byte("new master key 0123456789abcdef!")
+
+	// server without keyring wraps data keys with the built-in key
+	builtin := testStorage(t, nil)
+	legacyID, legacyWrapped, _ := wrapNewKey(t, builtin)
+	assert.Equal(t, keyring.LegacyID, legacyID)
+
+	old := testStorage(t, map[uint32][]byte{1: oldKey})
+	oldID, oldWrapped, oldCipher := wrapNewKey(t, old)
+	require.Equal(t, uint32(1), oldID)
+	data, meta, err := sealPair(oldCipher, "note", "meta")
+	require.NoError(t, err)
+
+	// during rotation both master keys and the built-in one unwrap data keys, new ones are wrapped by the new key
+	both := testStorage(t, map[uint32][]byte{1: oldKey, 2: newKey})
+	_, err = both.unwrapKey(legacyID, legacyWrapped)
+	require.NoError(t, err)
+	k, err := both.unwrapKey(oldID, oldWrapped)
+	require.NoError(t, err)
+	assert.NotEqual(t, both.ring.ActiveID(), k.masterID)
+	newID, newWrapped, newCipher := wrapNewKey(t, both)
+	require.Equal(t, uint32(2), newID)
+	rotatedData, rotatedMeta, err := rewrapPair(k.cipher, newCipher, data, meta)
+	require.NoError(t, err)
+
+	// legacy notes without data key are written with the built-in secret
+	plain, err := both.noteCipher(sql.NullInt64{})
+	require.NoError(t, err)
+	legacyData, legacyMeta, err := sealPair(plain, "old note", "")
+	require.NoError(t, err)
+	legacyData, legacyMeta, err = rewrapPair(plain, newCipher, legacyData, legacyMeta)
+	require.NoError(t, err)
+
+	// the old master key is retired, rotated notes are readable with the new one only
+	rotated := testStorage(t, map[uint32][]byte{2: newKey})
+	_, err = rotated.unwrapKey(oldID, oldWrapped)
+	assert.ErrorIs(t, err, ErrCorrupted)
+	k, err = rotated.unwrapKey(newID, newWrapped)
+	require.NoError(t, err)
+	opened, openedMeta, err := openPair(k.cipher, rotatedData, rotatedMeta)
+	require.NoError(t, err)
+	assert.Equal(t, "note", opened)
+	assert.Equal(t, "meta", openedMeta)
+	opened, _, err = openPair(k.cipher, legacyData, legacyMeta)
+	require.NoError(t, err)
+	assert.Equal(t, "old note", opened)
+
+	// notes sealed with another data key aren't moved
+	_, _, err = rewrapPair(newCipher, oldCipher, data, meta)
+	assert.ErrorIs(t, err, ErrCorrupted)
+}
@@ -0,0 +1,70 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"database/sql"
+	"errors"
+
+	"gophkeeper/internal/utils"
+)
+
+// KeyHistory returns previous vault keys of the user sealed by the client, oldest first.
+func (dbs *DBStorage) KeyHistory(userID uint32) ([][]byte, error) {
+	rows, err := dbs.db.Query("select sealed_key from vault_key_history where user_id=$1 order by position;", userID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+	var history [][]byte
+	for rows.Next() {
+		var key []byte
+		if err = rows.Scan(&key); err != nil {
+			return nil, ErrInternal
+		}
+		history = append(history, key)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	return history, nil
+}
+
+// ChangePassword checks the old password and saves hash of the new one with the new vault salt and key history in one transaction.
+// Key history is sealed by the client with the new vault key, so data sealed with old keys stays readable until it is re-encrypted.
+func (dbs *DBStorage) ChangePassword(userID uint32, oldPassword string, newPassword string, salt []byte, history [][]byte) error {
+	tx, err := dbs.db.Begin()
+	if err != nil {
+		return ErrInternal
+	}
+	defer tx.Rollback()
+	var current string
+	err = tx.QueryRow("select password from users where id=$1 for update;", userID).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return ErrInternal
+	}
+	if ok, _ := utils.VerifyPassword(oldPassword, current); !ok {
+		return ErrWrongPassword
+	}
+	hash, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return ErrInternal
+	}
+	if _, err = tx.Exec("update users set password=$2, vault_salt=$3 where id=$1;", userID, hash, salt); err != nil {
+		return ErrInternal
+	}
+	if _, err = tx.Exec("delete from vault_key_history where user_id=$1;", userID); err != nil {
+		return ErrInternal
+	}
+	for i, key := range history {
+		if _, err = tx.Exec("insert into vault_key_history (user_id, position, sealed_key) values ($1, $2, $3);", userID, i, key); err != nil {
+			return ErrInternal
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return ErrInternal
+	}
+	return nil
+}
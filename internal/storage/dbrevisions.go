@@ -0,0 +1,85 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+)
+
+// ListRevisions returns previous versions of the note from the newest, rows are added by the keeper_revision trigger.
+func (dbs *DBStorage) ListRevisions(userID uint32, dataID string) ([]datamodels.Revision, error) {
+	rows, err := dbs.db.Query("select id, changed_at, revised_at, deleted from keeper_revisions where user_id=$1 and data_id=$2 order by id desc;", userID, dataID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+	var revisions []datamodels.Revision
+	for rows.Next() {
+		var r datamodels.Revision
+		if err = rows.Scan(&r.ID, &r.ChangedAt, &r.RevisedAt, &r.Deleted); err != nil {
+			return nil, ErrInternal
+		}
+		revisions = append(revisions, r)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	return revisions, nil
+}
+
+// GetRevision returns the version of the note, server encrypted values are decrypted like values of current notes.
+func (dbs *DBStorage) GetRevision(userID uint32, dataID string, revision int64) (datamodels.Data, error) {
+	row := dbs.db.QueryRow("select "+noteColumns+" from keeper_revisions where user_id=$1 and data_id=$2 and id=$3;", userID, dataID, revision)
+	v, err := dbs.scanNote(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return datamodels.Data{}, ErrNotFound
+	}
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	v.UserID = userID
+	return v, nil
+}
+
+// RestoreRevision makes the revision the current version of the note changed now.
+// The trigger keeps the replaced version as a new revision, so restore can be undone.
+func (dbs *DBStorage) RestoreRevision(userID uint32, dataID string, revision int64) error {
+	res, err := dbs.db.Exec(`insert into keeper (data_id, user_id, data_info, meta_info, data_blob, meta_blob, key_id, data_key_id, deleted, changed_at)
+select data_id, user_id, data_info, meta_info, data_blob, meta_blob, key_id, data_key_id, false, now() from keeper_revisions where user_id=$1 and data_id=$2 and id=$3
+ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, data_blob=EXCLUDED.data_blob, meta_blob=EXCLUDED.meta_blob,
+key_id=EXCLUDED.key_id, data_key_id=EXCLUDED.data_key_id, deleted=false, changed_at=EXCLUDED.changed_at;`, userID, dataID, revision)
+	if err != nil {
+		return ErrInternal
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// PruneRevisions keeps at most keep newest revisions of every note and removes revisions older than maxAge,
+// zero keep or maxAge disables the limit. It returns amount of removed revisions.
+func (dbs *DBStorage) PruneRevisions(keep int, maxAge time.Duration) (int64, error) {
+	var removed int64
+	if maxAge > 0 {
+		res, err := dbs.db.Exec("delete from keeper_revisions where revised_at < $1;", time.Now().Add(-maxAge))
+		if err != nil {
+			return removed, ErrInternal
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	if keep > 0 {
+		res, err := dbs.db.Exec(`delete from keeper_revisions where id in (
+select id from (select id, row_number() over (partition by user_id, data_id order by id desc) as n from keeper_revisions) ranked where n > $1);`, keep)
+		if err != nil {
+			return removed, ErrInternal
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	return removed, nil
+}
@@ -2,12 +2,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
+	"log"
+	"sync"
 	"time"
 
 	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/keyring"
 	"gophkeeper/internal/utils"
 	pb "gophkeeper/proto"
 
@@ -19,16 +22,67 @@ import (
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-// dbSecret - secret key for cipher
+// dbSecret - key used by the server before keyring was introduced, notes without data key are encrypted with it
 var dbSecret = []byte("alskdjfhgnbvcmrt")
 
 // DBStorage is a struct that represents a storage implementation using a PostgreSQL database.
+// Notes are encrypted with per-user data keys which are wrapped by master keys from the keyring.
 type DBStorage struct {
-	db *sql.DB
+	db        *sql.DB
+	ring      *keyring.Keyring
+	legacy    utils.Cipher
+	dataKeys  map[int64]dataKey
+	keysMutex sync.RWMutex
+}
+
+// ServerStorage - storage functions used by the server in addition to Storage
+type ServerStorage interface {
+	Storage
+	// VaultSalt returns salt for end-to-end key derivation of the user.
+	VaultSalt(userID uint32) ([]byte, error)
+	// RotateKeys re-encrypts notes with the newest master key.
+	RotateKeys(ctx context.Context) error
+	// SetTOTP saves unconfirmed TOTP secret of the user.
+	SetTOTP(userID uint32, secret []byte) error
+	// TOTP returns TOTP secret of the user and whether it is confirmed.
+	TOTP(userID uint32) ([]byte, bool, error)
+	// ConfirmTOTP enables two-factor authentication with the recovery codes.
+	ConfirmTOTP(userID uint32, recoveryHashes []string) error
+	// UseTOTPStep prevents reuse of a TOTP code.
+	UseTOTPStep(userID uint32, step int64) (bool, error)
+	// UseRecoveryCode consumes a recovery code.
+	UseRecoveryCode(userID uint32, hash string) (bool, error)
+	// DisableTOTP turns two-factor authentication off.
+	DisableTOTP(userID uint32) error
+	// UserID returns id of the login.
+	UserID(login string) (uint32, error)
+	// KeyHistory returns previous vault keys of the user sealed by the client.
+	KeyHistory(userID uint32) ([][]byte, error)
+	// ChangePassword replaces password, vault salt and key history of the user.
+	ChangePassword(userID uint32, oldPassword string, newPassword string, salt []byte, history [][]byte) error
+	// ListRevisions returns previous versions of the note from the newest.
+	ListRevisions(userID uint32, dataID string) ([]datamodels.Revision, error)
+	// GetRevision returns previous version of the note.
+	GetRevision(userID uint32, dataID string, revision int64) (datamodels.Data, error)
+	// RestoreRevision makes previous version the current one.
+	RestoreRevision(userID uint32, dataID string, revision int64) error
+	// PruneRevisions removes revisions beyond the retention limits.
+	PruneRevisions(keep int, maxAge time.Duration) (int64, error)
+	// ListDeleted returns notes in the trash.
+	ListDeleted(userID uint32) ([]datamodels.Data, error)
+	// Undelete restores the note from the trash.
+	Undelete(userID uint32, dataID string) error
+	// Purge erases notes in the trash, empty dataID erases all of them. It returns ids of erased notes.
+	Purge(userID uint32, dataID string) ([]string, error)
+	// DeviceSynced saves time of the last synchronization of the device.
+	DeviceSynced(userID uint32, device string) error
+	// RemoveTombstones removes tombstones older than maxAge which all devices of the user have synced and returns them.
+	RemoveTombstones(maxAge time.Duration) ([]datamodels.UniqueData, error)
 }
 
 // NewDBStorage creates a new DBStorage instance with the provided database path.
-func NewDBStorage(path string) (Storage, error) {
+// If ring is nil the legacy server secret is used as the only master key, otherwise it is added to ring as the retired key keyring.LegacyID.
+func NewDBStorage(path string, ring *keyring.Keyring) (*DBStorage, error) {
 	if path == "" {
 		return nil, errors.New("invalid db address")
 	}
@@ -49,12 +103,40 @@ func NewDBStorage(path string) (Storage, error) {
 	if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 		return nil, err
 	}
-	return &DBStorage{db: db}, nil
+	legacy, err := utils.NewCipher(dbSecret)
+	if err != nil {
+		return nil, err
+	}
+	ring, err = withLegacyKey(ring)
+	if err != nil {
+		return nil, err
+	}
+	return &DBStorage{db: db, ring: ring, legacy: legacy, dataKeys: make(map[int64]dataKey)}, nil
+}
+
+// withLegacyKey - data keys of servers started without keyring are wrapped by the built-in key, it always stays to unwrap them
+func withLegacyKey(ring *keyring.Keyring) (*keyring.Keyring, error) {
+	if ring == nil {
+		return keyring.New(map[uint32][]byte{keyring.LegacyID: dbSecret})
+	}
+	if err := ring.AddRetired(keyring.LegacyID, dbSecret); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// DB returns connection pool of the storage, it is shared with other server components.
+func (dbs *DBStorage) DB() *sql.DB {
+	return dbs.db
 }
 
-// Auth adds a new user with the provided login and password to the storage.
+// Auth adds a new user with the provided login and password to the storage, only Argon2id hash of the password is saved.
 func (dbs *DBStorage) Auth(login string, password string) error {
-	_, err := dbs.db.Exec("insert into users (login, password) values ($1, $2);", login, password)
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return ErrInternal
+	}
+	_, err = dbs.db.Exec("insert into users (login, password) values ($1, $2);", login, hash)
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
 		return ErrDuplicate
@@ -62,7 +144,21 @@ func (dbs *DBStorage) Auth(login string, password string) error {
 	return err
 }
 
+// UserID returns id of the user with the login, ErrNotFound if there is no such user.
+func (dbs *DBStorage) UserID(login string) (uint32, error) {
+	var id uint32
+	err := dbs.db.QueryRow("select id from users where login=$1;", login).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, ErrInternal
+	}
+	return id, nil
+}
+
 // Login verifies the login credentials of a user and returns the user ID if successful.
+// Legacy MD5 hash is replaced with Argon2id hash after successful login.
 func (dbs *DBStorage) Login(login string, password string) (uint32, error) {
 	rows := dbs.db.QueryRow("select id,password from users where login=$1 limit 1;", login)
 	var v datamodels.Login
@@ -70,62 +166,151 @@ func (dbs *DBStorage) Login(login string, password string) (uint32, error) {
 	if err != nil {
 		return 0, ErrNotFound
 	}
-	if v.Password != password {
+	ok, rehash := utils.VerifyPassword(password, v.Password)
+	if !ok {
 		return 0, ErrWrongPassword
 	}
+	if rehash {
+		hash, err := utils.HashPassword(password)
+		if err == nil {
+			_, err = dbs.db.Exec("update users set password=$2 where id=$1 and password=$3;", v.ID, hash, v.Password)
+		}
+		if err != nil {
+			log.Printf("password rehash of user %d failed: %v", v.ID, err)
+		}
+	}
 	return v.ID, nil
 }
 
-// AddData adds new data to the storage.
-func (dbs *DBStorage) AddData(data datamodels.Data) error {
-	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at) values ($1, $2,$3,$4,$5) ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at where keeper.changed_at < $5;`
-	data.Data = utils.Encrypt(data.Data, dbSecret)
-	data.Metadata = utils.Encrypt(data.Metadata, dbSecret)
-	_, err := dbs.db.Exec(query, data.DataID, data.UserID, data.Data, data.Metadata, data.ChangedAt.Format(time.RFC3339))
+// noteColumns - columns selected for every note
+const noteColumns = `data_id, data_info, meta_info, data_blob, meta_blob, key_id, deleted, changed_at, data_key_id`
+
+// upsertQuery - inserts note or replaces its older version.
+// End-to-end envelope also replaces server encrypted row of the same age, this is how legacy rows are migrated.
+// The same note sealed with another vault key replaces the row too, this is how notes move to the key of a new password.
+const upsertQuery = `insert into keeper (data_id, user_id, data_info, meta_info, data_blob, meta_blob, key_id, changed_at, deleted, data_key_id) values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, data_blob=EXCLUDED.data_blob, meta_blob=EXCLUDED.meta_blob, key_id=EXCLUDED.key_id, changed_at=EXCLUDED.changed_at, deleted=EXCLUDED.deleted, data_key_id=EXCLUDED.data_key_id, purged=false
+where keeper.changed_at < EXCLUDED.changed_at or (keeper.key_id = '' and EXCLUDED.key_id <> '' and date_trunc('second', keeper.changed_at) <= EXCLUDED.changed_at)
+or (EXCLUDED.key_id <> '' and keeper.key_id <> EXCLUDED.key_id and keeper.changed_at = EXCLUDED.changed_at);`
+
+// rowScanner - common interface of sql.Row and sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanNote - reads note selected with noteColumns, server encrypted values are decrypted
+func (dbs *DBStorage) scanNote(row rowScanner) (datamodels.Data, error) {
+	var v datamodels.Data
+	var dataKeyID sql.NullInt64
+	err := row.Scan(&v.DataID, &v.Data, &v.Metadata, &v.DataBlob, &v.MetaBlob, &v.KeyID, &v.Deleted, &v.ChangedAt, &dataKeyID)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	if v.KeyID != "" {
+		// end-to-end envelopes are returned as is, server has no key for them
+		return v, nil
+	}
+	c, err := dbs.noteCipher(dataKeyID)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	v.Data, v.Metadata, err = openPair(c, v.Data, v.Metadata)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	return v, nil
+}
+
+// upsert - saves note, values without client key id are encrypted with the user data key
+func (dbs *DBStorage) upsert(data datamodels.Data) error {
+	var dataKeyID sql.NullInt64
+	if data.KeyID == "" {
+		id, c, err := dbs.userKey(data.UserID)
+		if err != nil {
+			return err
+		}
+		data.Data, data.Metadata, err = sealPair(c, data.Data, data.Metadata)
+		if err != nil {
+			return ErrInternal
+		}
+		data.DataBlob, data.MetaBlob = nil, nil
+		dataKeyID = sql.NullInt64{Int64: id, Valid: true}
+	} else {
+		data.Data, data.Metadata = "", ""
+	}
+	if data.Deleted {
+		// tombstone of a note the server doesn't have is not saved, so removed tombstones don't come back from devices
+		var exists bool
+		err := dbs.db.QueryRow("select exists(select 1 from keeper where user_id=$1 and data_id=$2);", data.UserID, data.DataID).Scan(&exists)
+		if err != nil {
+			return ErrInternal
+		}
+		if !exists {
+			return nil
+		}
+	}
+	_, err := dbs.db.Exec(upsertQuery, data.DataID, data.UserID, data.Data, data.Metadata, data.DataBlob, data.MetaBlob, data.KeyID, data.ChangedAt.Format(time.RFC3339), data.Deleted, dataKeyID)
 	if err != nil {
 		return ErrInternal
 	}
 	return nil
 }
 
+// AddData adds new data to the storage.
+func (dbs *DBStorage) AddData(data datamodels.Data) error {
+	data.Deleted = false
+	return dbs.upsert(data)
+}
+
 // GetData retrieves data from the storage based on the data ID and user ID.
 func (dbs *DBStorage) GetData(dataID string, userID uint32) (datamodels.Data, error) {
-	rows := dbs.db.QueryRow("select data_info,meta_info, changed_at from keeper where data_id=$1 and user_id=$2 and deleted=false limit 1;", dataID, userID)
-	var v datamodels.Data
-	err := rows.Scan(&v.Data, &v.Metadata, &v.ChangedAt)
-	v.Data = utils.Decrypt(v.Data, dbSecret)
-	v.Metadata = utils.Decrypt(v.Metadata, dbSecret)
-	if err != nil {
+	row := dbs.db.QueryRow("select "+noteColumns+" from keeper where data_id=$1 and user_id=$2 and deleted=false limit 1;", dataID, userID)
+	v, err := dbs.scanNote(row)
+	if errors.Is(err, sql.ErrNoRows) {
 		return datamodels.Data{}, ErrNotFound
 	}
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	v.UserID = userID
 	return v, nil
 }
 
-// DelData marks data as deleted in the storage based on the data ID and user ID.
+// DelData moves data to the trash based on the data ID and user ID, deletion is a change other devices sync.
 func (dbs *DBStorage) DelData(dataID string, userID uint32) error {
-	_, err := dbs.db.Exec("UPDATE  keeper set deleted=true where data_id=$1 and user_id=$2;", dataID, userID)
+	_, err := dbs.db.Exec("UPDATE  keeper set deleted=true, changed_at=now() where data_id=$1 and user_id=$2 and deleted=false;", dataID, userID)
 	if err != nil {
 		return ErrInternal
 	}
 	return nil
 }
 
-// Sync retrieves all data associated with a user from the storage.
+// Sync retrieves all data associated with a user from the storage, purged notes are returned as empty tombstones.
 func (dbs *DBStorage) Sync(userID uint32) ([]datamodels.Data, error) {
-	rows, err := dbs.db.Query("SELECT(data_id,data_info,meta_info,deleted,changed_at) from keeper where  user_id=$1;", userID)
+	resp, err := dbs.purgedNotes(userID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := dbs.db.Query("select "+noteColumns+" from keeper where user_id=$1 and purged=false;", userID)
 	if err != nil {
 		return nil, ErrInternal
 	}
-	var resp []datamodels.Data
-	var tmp datamodels.Data
+	defer rows.Close()
 
 	for rows.Next() {
-		err = rows.Scan(&tmp.DataID, &tmp.Data, &tmp.Metadata, &tmp.Deleted, &tmp.ChangedAt)
-		tmp.Data = utils.Decrypt(tmp.Data, dbSecret)
-		tmp.Metadata = utils.Decrypt(tmp.Metadata, dbSecret)
-		if err == nil {
-			resp = append(resp, tmp)
+		tmp, err := dbs.scanNote(rows)
+		// a note left out would look deleted to the client, so sync fails instead
+		if errors.Is(err, ErrCorrupted) {
+			return nil, err
 		}
+		if err != nil {
+			return nil, ErrInternal
+		}
+		tmp.UserID = userID
+		resp = append(resp, tmp)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
 	}
 	if resp != nil {
 		return resp, nil
@@ -135,19 +320,38 @@ func (dbs *DBStorage) Sync(userID uint32) ([]datamodels.Data, error) {
 
 // ClientSync synchronizes client data with the server in the storage.
 func (dbs *DBStorage) ClientSync(userID uint32, data []*pb.Data) error {
-	query := `insert into keeper (data_id,user_id, data_info,meta_info, changed_at,deleted) values ($1, $2,$3,$4,$5,$6) ON CONFLICT (user_id, data_id) DO UPDATE SET data_info=EXCLUDED.data_info, meta_info=EXCLUDED.meta_info, changed_at=EXCLUDED.changed_at where keeper.changed_at < $5;`
 	for i := range data {
-
-		fmt.Println(i, data[i].Data)
-		data[i].Data = utils.Encrypt(data[i].Data, dbSecret)
-		fmt.Println(i, data[i].Data)
-		fmt.Println(i, data[i].MetaInfo)
-		data[i].MetaInfo = utils.Encrypt(data[i].MetaInfo, dbSecret)
-		fmt.Println(i, data[i].MetaInfo)
-		_, err := dbs.db.Exec(query, data[i].DataId, userID, data[i].Data, data[i].MetaInfo, data[i].ChangedAt.AsTime().Format(time.RFC3339), data[i].Deleted)
+		err := dbs.upsert(datamodels.Data{
+			UserID:    userID,
+			DataID:    data[i].DataId,
+			Data:      data[i].Data,
+			Metadata:  data[i].MetaInfo,
+			DataBlob:  data[i].DataBlob,
+			MetaBlob:  data[i].MetaBlob,
+			KeyID:     data[i].KeyId,
+			ChangedAt: data[i].ChangedAt.AsTime(),
+			Deleted:   data[i].Deleted,
+		})
 		if err != nil {
-			return ErrInternal
+			return err
 		}
 	}
 	return nil
 }
+
+// VaultSalt returns salt used by clients of the user to derive end-to-end key, salt is created on first call.
+func (dbs *DBStorage) VaultSalt(userID uint32) ([]byte, error) {
+	salt, err := utils.NewSalt()
+	if err != nil {
+		return nil, ErrInternal
+	}
+	_, err = dbs.db.Exec("update users set vault_salt=$2 where id=$1 and vault_salt is null;", userID, salt)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	err = dbs.db.QueryRow("select vault_salt from users where id=$1;", userID).Scan(&salt)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return salt, nil
+}
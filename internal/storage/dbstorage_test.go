@@ -1 +1,173 @@
 package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/keyring"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDB - opens the database of TEST_DATABASE_DSN with the keyring, migrations are read from the repository root
+func testDB(t *testing.T, ring *keyring.Keyring) *DBStorage {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir("../.."))
+	t.Cleanup(func() { os.Chdir(wd) })
+	dbs, err := NewDBStorage(dsn, ring)
+	require.NoError(t, err)
+	return dbs
+}
+
+// testUser - registers a new user, logins of earlier runs are taken, so every run uses a new one
+func testUser(t *testing.T, dbs *DBStorage, prefix string) uint32 {
+	login := fmt.Sprintf("%s%d", prefix, time.Now().UnixNano())
+	require.NoError(t, dbs.Auth(login, "password"))
+	userID, err := dbs.UserID(login)
+	require.NoError(t, err)
+	return userID
+}
+
+func TestDBStorage_RotateKeys(t *testing.T) {
+	// master key ids of earlier runs are already rotated, so every run uses new ones
+	oldID := uint32(time.Now().Unix())
+	oldKey, newKey := make([]byte, 32), make([]byte, 32)
+	newKey[0] = 1
+	oldRing, err := keyring.New(map[uint32][]byte{oldID: oldKey})
+	require.NoError(t, err)
+	dbs := testDB(t, oldRing)
+
+	userID := testUser(t, dbs, "rotation")
+	changed := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, dbs.AddData(datamodels.Data{UserID: userID, DataID: "note", Data: "first", Metadata: "meta", ChangedAt: changed}))
+	require.NoError(t, dbs.AddData(datamodels.Data{UserID: userID, DataID: "note", Data: "second", Metadata: "meta", ChangedAt: changed.Add(time.Minute)}))
+	require.NoError(t, dbs.SetTOTP(userID, []byte("totp secret")))
+
+	bothRing, err := keyring.New(map[uint32][]byte{oldID: oldKey, oldID + 1: newKey})
+	require.NoError(t, err)
+	require.NoError(t, testDB(t, bothRing).RotateKeys(context.Background()))
+
+	// the old master key is retired, notes, revisions and TOTP secrets are readable with the new one only
+	newRing, err := keyring.New(map[uint32][]byte{oldID + 1: newKey})
+	require.NoError(t, err)
+	rotated := testDB(t, newRing)
+	note, err := rotated.GetData("note", userID)
+	require.NoError(t, err)
+	assert.Equal(t, "second", note.Data)
+	revisions, err := rotated.ListRevisions(userID, "note")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	revision, err := rotated.GetRevision(userID, "note", revisions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", revision.Data)
+	secret, _, err := rotated.TOTP(userID)
+	require.NoError(t, err)
+	assert.Equal(t, "totp secret", string(secret))
+}
+
+// removedOf - notes of the user among removed tombstones
+func removedOf(removed []datamodels.UniqueData, userID uint32) []string {
+	var ids []string
+	for _, v := range removed {
+		if v.UserID == userID {
+			ids = append(ids, v.DataID)
+		}
+	}
+	return ids
+}
+
+func TestDBStorage_Trash(t *testing.T) {
+	dbs := testDB(t, nil)
+	userID := testUser(t, dbs, "trash")
+	// the phone has synced before the deletion, so tombstones stay until it syncs again
+	require.NoError(t, dbs.DeviceSynced(userID, "phone"))
+	for _, id := range []string{"kept", "trashed", "purged", "restored"} {
+		require.NoError(t, dbs.AddData(datamodels.Data{UserID: userID, DataID: id, Data: "data " + id, Metadata: "meta", ChangedAt: time.Now()}))
+	}
+	for _, id := range []string{"trashed", "purged", "restored"} {
+		require.NoError(t, dbs.DelData(id, userID))
+	}
+
+	deleted, err := dbs.ListDeleted(userID)
+	require.NoError(t, err)
+	require.Len(t, deleted, 3)
+	assert.Equal(t, "data purged", deleted[1].Data)
+
+	require.NoError(t, dbs.Undelete(userID, "restored"))
+	assert.ErrorIs(t, dbs.Undelete(userID, "restored"), ErrNotFound)
+	note, err := dbs.GetData("restored", userID)
+	require.NoError(t, err)
+	assert.Equal(t, "data restored", note.Data)
+
+	purged, err := dbs.Purge(userID, "purged")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"purged"}, purged)
+	_, err = dbs.Purge(userID, "purged")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = dbs.Purge(userID, "kept")
+	assert.ErrorIs(t, err, ErrNotFound)
+	revisions, err := dbs.ListRevisions(userID, "purged")
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+	deleted, err = dbs.ListDeleted(userID)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, "trashed", deleted[0].DataID)
+	notes, err := dbs.Sync(userID)
+	require.NoError(t, err)
+	var tombstone *datamodels.Data
+	for i := range notes {
+		if notes[i].DataID == "purged" {
+			tombstone = &notes[i]
+		}
+	}
+	require.NotNil(t, tombstone)
+	assert.True(t, tombstone.Purged)
+	assert.Empty(t, tombstone.Data)
+
+	time.Sleep(10 * time.Millisecond)
+	removed, err := dbs.RemoveTombstones(0)
+	require.NoError(t, err)
+	assert.Empty(t, removedOf(removed, userID))
+	require.NoError(t, dbs.DeviceSynced(userID, "laptop"))
+	removed, err = dbs.RemoveTombstones(0)
+	require.NoError(t, err)
+	assert.Empty(t, removedOf(removed, userID))
+
+	// every device has synced after the deletion
+	require.NoError(t, dbs.DeviceSynced(userID, "phone"))
+	removed, err = dbs.RemoveTombstones(0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"trashed", "purged"}, removedOf(removed, userID))
+	deleted, err = dbs.ListDeleted(userID)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+	_, err = dbs.GetData("kept", userID)
+	assert.NoError(t, err)
+}
+
+func TestDBStorage_RemoveTombstonesWithoutDevices(t *testing.T) {
+	dbs := testDB(t, nil)
+	userID := testUser(t, dbs, "nodevices")
+	require.NoError(t, dbs.AddData(datamodels.Data{UserID: userID, DataID: "note", Data: "data", ChangedAt: time.Now()}))
+	require.NoError(t, dbs.DelData("note", userID))
+	time.Sleep(10 * time.Millisecond)
+
+	// no device has acknowledged the deletion, so the tombstone stays
+	removed, err := dbs.RemoveTombstones(0)
+	require.NoError(t, err)
+	assert.Empty(t, removedOf(removed, userID))
+	deleted, err := dbs.ListDeleted(userID)
+	require.NoError(t, err)
+	assert.Len(t, deleted, 1)
+}
@@ -0,0 +1,125 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// SetTOTP saves new unconfirmed TOTP secret of the user encrypted with the user data key.
+// Unconfirmed secret is replaced, confirmed one can only be removed by DisableTOTP.
+func (dbs *DBStorage) SetTOTP(userID uint32, secret []byte) error {
+	keyID, c, err := dbs.userKey(userID)
+	if err != nil {
+		return err
+	}
+	sealed, err := c.Seal(secret)
+	if err != nil {
+		return ErrInternal
+	}
+	res, err := dbs.db.Exec(`insert into totp (user_id, secret, data_key_id) values ($1, $2, $3)
+ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, data_key_id=EXCLUDED.data_key_id, last_step=0 where not totp.confirmed;`, userID, sealed, keyID)
+	if err != nil {
+		return ErrInternal
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrTOTPEnabled
+	}
+	return nil
+}
+
+// TOTP returns TOTP secret of the user and whether it is confirmed, ErrNotFound means two-factor authentication is off.
+func (dbs *DBStorage) TOTP(userID uint32) ([]byte, bool, error) {
+	var sealed []byte
+	var keyID int64
+	var confirmed bool
+	err := dbs.db.QueryRow("select secret, data_key_id, confirmed from totp where user_id=$1;", userID).Scan(&sealed, &keyID, &confirmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, ErrNotFound
+	}
+	if err != nil {
+		return nil, false, ErrInternal
+	}
+	k, err := dbs.dataKey(keyID)
+	if err != nil {
+		return nil, false, err
+	}
+	secret, err := k.cipher.Open(sealed)
+	if err != nil {
+		return nil, false, ErrCorrupted
+	}
+	return secret, confirmed, nil
+}
+
+// ConfirmTOTP enables two-factor authentication of the user and replaces recovery codes with the hashes.
+func (dbs *DBStorage) ConfirmTOTP(userID uint32, recoveryHashes []string) error {
+	tx, err := dbs.db.Begin()
+	if err != nil {
+		return ErrInternal
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec("update totp set confirmed=true where user_id=$1 and not confirmed;", userID)
+	if err != nil {
+		return ErrInternal
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrTOTPEnabled
+	}
+	if _, err = tx.Exec("delete from recovery_codes where user_id=$1;", userID); err != nil {
+		return ErrInternal
+	}
+	for _, hash := range recoveryHashes {
+		if _, err = tx.Exec("insert into recovery_codes (user_id, code_hash) values ($1, $2);", userID, hash); err != nil {
+			return ErrInternal
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return ErrInternal
+	}
+	return nil
+}
+
+// UseTOTPStep marks time step of an accepted code as used, false means the code was already used.
+func (dbs *DBStorage) UseTOTPStep(userID uint32, step int64) (bool, error) {
+	res, err := dbs.db.Exec("update totp set last_step=$2 where user_id=$1 and last_step < $2;", userID, step)
+	if err != nil {
+		return false, ErrInternal
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, ErrInternal
+	}
+	return n > 0, nil
+}
+
+// UseRecoveryCode marks recovery code with the hash as used, false means there is no such unused code.
+func (dbs *DBStorage) UseRecoveryCode(userID uint32, hash string) (bool, error) {
+	res, err := dbs.db.Exec("update recovery_codes set used=true where user_id=$1 and code_hash=$2 and not used;", userID, hash)
+	if err != nil {
+		return false, ErrInternal
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, ErrInternal
+	}
+	return n > 0, nil
+}
+
+// DisableTOTP removes TOTP secret and recovery codes of the user.
+func (dbs *DBStorage) DisableTOTP(userID uint32) error {
+	tx, err := dbs.db.Begin()
+	if err != nil {
+		return ErrInternal
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("delete from recovery_codes where user_id=$1;", userID); err != nil {
+		return ErrInternal
+	}
+	if _, err = tx.Exec("delete from totp where user_id=$1;", userID); err != nil {
+		return ErrInternal
+	}
+	if err = tx.Commit(); err != nil {
+		return ErrInternal
+	}
+	return nil
+}
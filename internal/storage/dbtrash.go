@@ -0,0 +1,163 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"gophkeeper/internal/datamodels"
+)
+
+// ListDeleted returns notes in the trash from the last deleted.
+func (dbs *DBStorage) ListDeleted(userID uint32) ([]datamodels.Data, error) {
+	rows, err := dbs.db.Query("select "+noteColumns+" from keeper where user_id=$1 and deleted and purged=false order by changed_at desc;", userID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+	var resp []datamodels.Data
+	for rows.Next() {
+		v, err := dbs.scanNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		v.UserID = userID
+		resp = append(resp, v)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	return resp, nil
+}
+
+// Undelete restores the note from the trash, restore is a change other devices sync.
+func (dbs *DBStorage) Undelete(userID uint32, dataID string) error {
+	res, err := dbs.db.Exec("update keeper set deleted=false, changed_at=now() where user_id=$1 and data_id=$2 and deleted and purged=false;", userID, dataID)
+	if err != nil {
+		return ErrInternal
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Purge erases notes in the trash with their revisions, empty dataID erases the whole trash.
+// An empty tombstone is left, so devices which still have the note drop it on sync instead of bringing it back.
+// It returns ids of erased notes.
+func (dbs *DBStorage) Purge(userID uint32, dataID string) ([]string, error) {
+	tx, err := dbs.db.Begin()
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`update keeper set data_info='', meta_info='', data_blob=null, meta_blob=null, key_id='', data_key_id=null, purged=true, changed_at=now()
+where user_id=$1 and ($2='' or data_id=$2) and deleted and purged=false returning data_id;`, userID, dataID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	purged, err := scanIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(purged) == 0 && dataID != "" {
+		return nil, ErrNotFound
+	}
+	// the trigger has just saved the erased version too
+	for _, id := range purged {
+		if _, err = tx.Exec("delete from keeper_revisions where user_id=$1 and data_id=$2;", userID, id); err != nil {
+			return nil, ErrInternal
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, ErrInternal
+	}
+	return purged, nil
+}
+
+// purgedNotes - tombstones of purged notes of the user
+func (dbs *DBStorage) purgedNotes(userID uint32) ([]datamodels.Data, error) {
+	rows, err := dbs.db.Query("select data_id, changed_at from keeper where user_id=$1 and purged;", userID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer rows.Close()
+	var resp []datamodels.Data
+	for rows.Next() {
+		v := datamodels.Data{UserID: userID, Deleted: true, Purged: true}
+		if err = rows.Scan(&v.DataID, &v.ChangedAt); err != nil {
+			return nil, ErrInternal
+		}
+		resp = append(resp, v)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	return resp, nil
+}
+
+// DeviceSynced saves time of the last synchronization of the device, tombstones are kept until every device has synced them.
+func (dbs *DBStorage) DeviceSynced(userID uint32, device string) error {
+	_, err := dbs.db.Exec(`insert into device_syncs (user_id, device, synced_at) values ($1, $2, now())
+ON CONFLICT (user_id, device) DO UPDATE SET synced_at=EXCLUDED.synced_at;`, userID, device)
+	if err != nil {
+		return ErrInternal
+	}
+	return nil
+}
+
+// RemoveTombstones removes deleted and purged notes older than maxAge with their revisions
+// when the user has known devices and every one of them has synced after the deletion. It returns removed notes.
+func (dbs *DBStorage) RemoveTombstones(maxAge time.Duration) ([]datamodels.UniqueData, error) {
+	tx, err := dbs.db.Begin()
+	if err != nil {
+		return nil, ErrInternal
+	}
+	defer tx.Rollback()
+	// users without known devices keep their tombstones, nobody has acknowledged them yet
+	rows, err := tx.Query(`delete from keeper k where k.deleted and k.changed_at < $1
+and exists (select 1 from device_syncs d where d.user_id=k.user_id)
+and not exists (select 1 from device_syncs d where d.user_id=k.user_id and d.synced_at <= k.changed_at) returning k.user_id, k.data_id;`, time.Now().Add(-maxAge))
+	if err != nil {
+		return nil, ErrInternal
+	}
+	var removed []datamodels.UniqueData
+	for rows.Next() {
+		var v datamodels.UniqueData
+		if err = rows.Scan(&v.UserID, &v.DataID); err != nil {
+			rows.Close()
+			return nil, ErrInternal
+		}
+		removed = append(removed, v)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	for _, v := range removed {
+		if _, err = tx.Exec("delete from keeper_revisions where user_id=$1 and data_id=$2;", v.UserID, v.DataID); err != nil {
+			return nil, ErrInternal
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, ErrInternal
+	}
+	return removed, nil
+}
+
+// scanIDs - reads data ids returned by a query and closes rows
+func scanIDs(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, ErrInternal
+		}
+		ids = append(ids, id)
+	}
+	if rows.Err() != nil {
+		return nil, ErrInternal
+	}
+	return ids, nil
+}
@@ -1,8 +1,9 @@
-// Package filereaders provides functions for reading and writing data to a JSON file.
+// Package filereaders provides functions for reading and writing encrypted vault files and legacy JSON files.
 package filereaders
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"os"
@@ -11,19 +12,23 @@ import (
 )
 
 // ReadData reads data from a JSON file and returns a map of datamodels.UniqueData to datamodels.Data.
+// The file is written only by old clients, its lines are moved to vault files on login.
 func ReadData() (map[datamodels.UniqueData]datamodels.Data, error) {
-	file, err := os.OpenFile("data.json", os.O_RDONLY|os.O_CREATE, 0777)
+	store := make(map[datamodels.UniqueData]datamodels.Data)
+	file, err := os.Open("data.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
 	if err != nil {
 		return nil, errors.New("failed to open file")
 	}
 	defer file.Close()
 
-	store := make(map[datamodels.UniqueData]datamodels.Data)
 	var data []datamodels.Data
-	var tmp datamodels.Data
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		var tmp datamodels.Data
 		err = json.Unmarshal(scanner.Bytes(), &tmp)
 		if err != nil {
 			if err.Error() != "EOF" {
@@ -47,19 +52,50 @@ func ReadData() (map[datamodels.UniqueData]datamodels.Data, error) {
 	return store, nil
 }
 
-// WriteData writes the provided data to a JSON file.
-func WriteData(data datamodels.Data) error {
-	file, err := os.OpenFile("data.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
-	if err != nil {
-		return errors.New("failed to open file")
+// DropLegacyUser removes lines of the user from the JSON files after they were moved to the vault file of the user.
+// Files left without lines are deleted.
+func DropLegacyUser(login string, id uint32) error {
+	if err := dropLines("users.json", func(line []byte) bool {
+		var v datamodels.Auth
+		return json.Unmarshal(line, &v) == nil && v.Login == login
+	}); err != nil {
+		return err
 	}
-	defer file.Close()
+	return dropLines("data.json", func(line []byte) bool {
+		var v datamodels.Data
+		return json.Unmarshal(line, &v) == nil && v.UserID == id
+	})
+}
 
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(data)
+// dropLines - atomically rewrites the file without lines matching drop
+func dropLines(name string, drop func(line []byte) bool) error {
+	raw, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
 	if err != nil {
-		return errors.New("failed to encode data")
+		return errors.New("failed to open file")
 	}
-
-	return nil
+	var kept []byte
+	dropped := false
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if drop(line) {
+			dropped = true
+			continue
+		}
+		kept = append(append(kept, line...), '\n')
+	}
+	if !dropped {
+		return nil
+	}
+	if len(kept) == 0 {
+		if err = os.Remove(name); err != nil {
+			return errors.New("failed to remove file")
+		}
+		return nil
+	}
+	return replaceFile(name, kept)
 }
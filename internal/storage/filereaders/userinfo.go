@@ -1,4 +1,4 @@
-// Package filereaders provides functions for reading and writing data to a JSON file.
+// Package filereaders provides functions for reading and writing encrypted vault files and legacy JSON files.
 package filereaders
 
 import (
@@ -12,17 +12,21 @@ import (
 )
 
 // ReadUsers reads data from a JSON file and returns sessionstorage.UserSession.
+// The file is written only by old clients, its lines are moved to vault files on login.
 func ReadUsers() (sessionstorage.UserSession, error) {
-	file, err := os.OpenFile("users.json", os.O_RDONLY|os.O_CREATE, 0777)
+	user := sessionstorage.Init()
+	file, err := os.Open("users.json")
+	if errors.Is(err, os.ErrNotExist) {
+		return user, nil
+	}
 	if err != nil {
 		return sessionstorage.UserSession{}, errors.New("failed to open file")
 	}
 	defer file.Close()
-	user := sessionstorage.Init()
 	var data []datamodels.Auth
-	var tmp datamodels.Auth
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
+		var tmp datamodels.Auth
 		err = json.Unmarshal(scanner.Bytes(), &tmp)
 		if err != nil {
 			if err.Error() != "EOF" {
@@ -31,23 +35,9 @@ func ReadUsers() (sessionstorage.UserSession, error) {
 		}
 		data = append(data, tmp)
 	}
+	// users file is append only, so the last line for login holds its actual state
 	for _, v := range data {
-		user.AddUser(v.Login, v.Password, v.ID)
+		user.SetUser(v.Login, datamodels.Login{ID: v.ID, Password: v.Password, Salt: v.Salt, KeyHistory: v.KeyHistory})
 	}
 	return user, nil
 }
-
-// WriteUser writes the provided data to a JSON file.
-func WriteUser(auth datamodels.Auth) error {
-	file, err := os.OpenFile("users.json", os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
-	if err != nil {
-		return errors.New("failed to open file")
-	}
-	defer file.Close()
-	encoder := json.NewEncoder(file)
-	err = encoder.Encode(auth)
-	if err != nil {
-		return errors.New("failed to encode data")
-	}
-	return nil
-}
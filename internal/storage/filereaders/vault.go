@@ -0,0 +1,216 @@
+// Package filereaders provides functions for reading and writing encrypted vault files and legacy JSON files.
+package filereaders
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+)
+
+// VaultVersion - format version written into the header of new vault files
+const VaultVersion = 1
+
+// kdfName - key derivation function of the vault key
+const kdfName = "argon2id"
+
+// Limits of KDF parameters read from the header, it is not authenticated before the key is derived
+const (
+	maxKDFTime   = 16
+	maxKDFMemory = 1024 * 1024 // KiB
+	vaultKeyLen  = 32
+)
+
+// vaultMagic - first bytes of every vault file
+var vaultMagic = []byte("GKVAULT")
+
+// Vault file errors
+var (
+	ErrNoVault     = errors.New("local vault not found")
+	ErrVaultKey    = errors.New("wrong password for local vault")
+	ErrTampered    = errors.New("local vault file is corrupted or was modified")
+	ErrVaultFormat = errors.New("unsupported local vault format")
+)
+
+// KDF - how the vault key is derived from the master password
+type KDF struct {
+	Name string `json:"name"`
+	utils.KDFParams
+	Salt []byte `json:"salt"`
+}
+
+// Header - plaintext part of the vault file, it is authenticated together with the encrypted contents
+type Header struct {
+	Version int `json:"version"`
+	KDF     KDF `json:"kdf"`
+	// Check - proves the key before contents are opened, so a wrong password is told apart from a modified file
+	Check []byte `json:"check"`
+}
+
+// Vault - contents of the vault file of one user
+type Vault struct {
+	User datamodels.Auth   `json:"user"`
+	Data []datamodels.Data `json:"data"`
+}
+
+// VaultPath - returns path of the vault file of the login in dir, the login itself is not visible in the name
+func VaultPath(dir string, login string) string {
+	sum := sha256.Sum256([]byte(login))
+	return filepath.Join(dir, hex.EncodeToString(sum[:16])+".vault")
+}
+
+// NewKDF - default key derivation with the salt
+func NewKDF(salt []byte) KDF {
+	return KDF{Name: kdfName, KDFParams: utils.DefaultKDF, Salt: salt}
+}
+
+// subkey - derives key for a single purpose from the vault key
+func subkey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// aead - AES-GCM of the vault file
+func aead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(subkey(key, "gophkeeper vault file"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readVaultFile - splits vault file into authenticated prefix with parsed header and sealed contents
+func readVaultFile(path string) ([]byte, Header, []byte, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, Header{}, nil, ErrNoVault
+	}
+	if err != nil {
+		return nil, Header{}, nil, errors.New("failed to open file")
+	}
+	if !bytes.HasPrefix(raw, vaultMagic) {
+		return nil, Header{}, nil, ErrVaultFormat
+	}
+	rest := raw[len(vaultMagic):]
+	if len(rest) < 4 {
+		return nil, Header{}, nil, ErrTampered
+	}
+	size := binary.BigEndian.Uint32(rest)
+	if uint64(size) > uint64(len(rest)-4) {
+		return nil, Header{}, nil, ErrTampered
+	}
+	prefixLen := len(vaultMagic) + 4 + int(size)
+	var header Header
+	if err = json.Unmarshal(raw[len(vaultMagic)+4:prefixLen], &header); err != nil {
+		return nil, Header{}, nil, ErrTampered
+	}
+	if header.Version != VaultVersion || header.KDF.Name != kdfName {
+		return nil, Header{}, nil, ErrVaultFormat
+	}
+	if !validKDF(header.KDF.KDFParams) {
+		return nil, Header{}, nil, ErrTampered
+	}
+	return raw[:prefixLen], header, raw[prefixLen:], nil
+}
+
+// validKDF - parameters argon2 accepts without panic and which can be derived in reasonable time and memory
+func validKDF(p utils.KDFParams) bool {
+	return p.Time >= 1 && p.Time <= maxKDFTime && p.Threads >= 1 && p.Memory >= 8*uint32(p.Threads) && p.Memory <= maxKDFMemory && p.KeyLen == vaultKeyLen
+}
+
+// ReadHeader returns header of the vault file, KDF parameters from it are needed to derive the key.
+func ReadHeader(path string) (Header, error) {
+	_, header, _, err := readVaultFile(path)
+	return header, err
+}
+
+// ReadVault decrypts the vault file with the vault key.
+// Authentication tag of AES-GCM covers the header and every byte of the contents, so any change is reported as ErrTampered.
+func ReadVault(path string, key []byte) (Vault, error) {
+	prefix, header, sealed, err := readVaultFile(path)
+	if err != nil {
+		return Vault{}, err
+	}
+	if !hmac.Equal(header.Check, subkey(key, "gophkeeper vault check")) {
+		return Vault{}, ErrVaultKey
+	}
+	gcm, err := aead(key)
+	if err != nil {
+		return Vault{}, ErrVaultKey
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return Vault{}, ErrTampered
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], prefix)
+	if err != nil {
+		return Vault{}, ErrTampered
+	}
+	var v Vault
+	if err = json.Unmarshal(plain, &v); err != nil {
+		return Vault{}, ErrTampered
+	}
+	return v, nil
+}
+
+// WriteVault encrypts contents with the vault key derived by kdf and atomically replaces the vault file.
+func WriteVault(path string, kdf KDF, key []byte, v Vault) error {
+	headerJSON, err := json.Marshal(Header{Version: VaultVersion, KDF: kdf, Check: subkey(key, "gophkeeper vault check")})
+	if err != nil {
+		return errors.New("failed to encode header")
+	}
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return errors.New("failed to encode data")
+	}
+	gcm, err := aead(key)
+	if err != nil {
+		return errors.New("failed to create cipher")
+	}
+	var buf bytes.Buffer
+	buf.Write(vaultMagic)
+	binary.Write(&buf, binary.BigEndian, uint32(len(headerJSON)))
+	buf.Write(headerJSON)
+	prefix := append([]byte(nil), buf.Bytes()...)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.New("failed to create nonce")
+	}
+	buf.Write(nonce)
+	return replaceFile(path, gcm.Seal(buf.Bytes(), nonce, plain, prefix))
+}
+
+// replaceFile - writes content to a temporary file which is renamed over the old one, so a crash leaves either old or new file
+func replaceFile(path string, content []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.New("failed to create file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err = tmp.Write(content); err != nil {
+		return errors.New("failed to write file")
+	}
+	if err = tmp.Sync(); err != nil {
+		return errors.New("failed to sync file")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.New("failed to close file")
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return errors.New("failed to replace file")
+	}
+	return nil
+}
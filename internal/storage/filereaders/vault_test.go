@@ -0,0 +1,98 @@
+package filereaders
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"gophkeeper/internal/datamodels"
+	"gophkeeper/internal/utils"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault(t *testing.T) {
+	path := VaultPath(t.TempDir(), "test")
+	key := make([]byte, 32)
+	content := Vault{User: datamodels.Auth{ID: 1, Login: "test"}, Data: []datamodels.Data{{UserID: 1, DataID: "card", Data: "sealed"}}}
+
+	_, err := ReadHeader(path)
+	assert.ErrorIs(t, err, ErrNoVault)
+	require.NoError(t, WriteVault(path, NewKDF([]byte("salt")), key, content))
+
+	header, err := ReadHeader(path)
+	require.NoError(t, err)
+	assert.Equal(t, VaultVersion, header.Version)
+	assert.Equal(t, []byte("salt"), header.KDF.Salt)
+	got, err := ReadVault(path, key)
+	require.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "card")
+
+	wrong := make([]byte, 32)
+	wrong[0] = 1
+	_, err = ReadVault(path, wrong)
+	assert.ErrorIs(t, err, ErrVaultKey)
+
+	raw[len(raw)-1] ^= 1
+	require.NoError(t, os.WriteFile(path, raw, 0600))
+	_, err = ReadVault(path, key)
+	assert.ErrorIs(t, err, ErrTampered)
+
+	require.NoError(t, os.WriteFile(path, []byte("{}"), 0600))
+	_, err = ReadVault(path, key)
+	assert.ErrorIs(t, err, ErrVaultFormat)
+}
+
+func TestVault_KDFParams(t *testing.T) {
+	key := make([]byte, 32)
+	tests := []struct {
+		name   string
+		params utils.KDFParams
+	}{
+		{name: "zero time", params: utils.KDFParams{Time: 0, Memory: 64 * 1024, Threads: 4, KeyLen: 32}},
+		{name: "zero threads", params: utils.KDFParams{Time: 1, Memory: 64 * 1024, Threads: 0, KeyLen: 32}},
+		{name: "huge memory", params: utils.KDFParams{Time: 1, Memory: 1 << 31, Threads: 4, KeyLen: 32}},
+		{name: "huge time", params: utils.KDFParams{Time: 1 << 20, Memory: 64 * 1024, Threads: 4, KeyLen: 32}},
+		{name: "short key", params: utils.KDFParams{Time: 1, Memory: 64 * 1024, Threads: 4, KeyLen: 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := VaultPath(t.TempDir(), "test")
+			require.NoError(t, WriteVault(path, NewKDF([]byte("salt")), key, Vault{}))
+			kdf := NewKDF([]byte("salt"))
+			kdf.KDFParams = tt.params
+			require.NoError(t, os.WriteFile(path, withHeader(t, path, kdf), 0600))
+
+			_, err := ReadHeader(path)
+			assert.ErrorIs(t, err, ErrTampered)
+			_, err = ReadVault(path, key)
+			assert.ErrorIs(t, err, ErrTampered)
+		})
+	}
+}
+
+// withHeader - vault file with KDF of the header replaced, as an attacker would edit it
+func withHeader(t *testing.T, path string, kdf KDF) []byte {
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	rest := raw[len(vaultMagic):]
+	size := binary.BigEndian.Uint32(rest)
+	var header Header
+	require.NoError(t, json.Unmarshal(rest[4:4+size], &header))
+	header.KDF = kdf
+	headerJSON, err := json.Marshal(header)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	buf.Write(vaultMagic)
+	require.NoError(t, binary.Write(&buf, binary.BigEndian, uint32(len(headerJSON))))
+	buf.Write(headerJSON)
+	buf.Write(rest[4+size:])
+	return buf.Bytes()
+}
@@ -2,30 +2,38 @@
 package storage
 
 import (
-	"context"
 	"errors"
+	"fmt"
 	"log"
+	"os"
+	"sort"
 	"time"
 
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
 	"gophkeeper/internal/sessionstorage"
 	files "gophkeeper/internal/storage/filereaders"
+	"gophkeeper/internal/tlsconfig"
 	"gophkeeper/internal/utils"
 	pb "gophkeeper/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
-	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Client - grpc default client
 var Client pb.GophkeeperClient
 
-// clientSecret - secret key for cipher
-var clientSecret = []byte("qpwoeritkvndgahz")
+// vaultDir - directory of vault files set by Init
+var vaultDir string
+
+// legacyClientSecret - key compiled into old clients, kept only to migrate their data.json to per-user keys
+var legacyClientSecret = []byte("qpwoeritkvndgahz")
 
 // Module errors
 var (
@@ -33,6 +41,11 @@ var (
 	ErrWrongPassword = errors.New("invalid password")
 	ErrInternal      = errors.New("server error")
 	ErrDuplicate     = errors.New("login already exists")
+	ErrCorrupted     = errors.New("data corrupted")
+	ErrLocked        = errors.New("vault is locked, login first")
+	ErrTOTPEnabled   = errors.New("two-factor authentication is already enabled")
+	// ErrLocalOnly - note is saved in the local vault, but server didn't get it, sync sends it later
+	ErrLocalOnly = errors.New("note is saved only locally, run sync to send it to the server")
 )
 
 // Storage an interface that defines the following methods:
@@ -58,21 +71,44 @@ var Users sessionstorage.UserSession
 var md metadata.MD
 
 // Init initializes the storage package by establishing a gRPC connection.
-func Init() {
-	conn, err := grpc.Dial(":3200", grpc.WithTransportCredentials(insecure.NewCredentials()))
+// Connection uses TLS unless cfg.Insecure is set.
+func Init(cfg config.Client) error {
+	creds := insecure.NewCredentials()
+	if !cfg.Insecure {
+		tlsCfg, err := tlsconfig.Client(cfg.CAFile, cfg.Pin, cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	}
+	conn, err := grpc.Dial(cfg.Address, grpc.WithTransportCredentials(creds))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	Client = pb.NewGophkeeperClient(conn)
+	device, _ = os.Hostname()
+	vaultDir = cfg.VaultDir
+	if vaultDir != "" {
+		if err = os.MkdirAll(vaultDir, 0700); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // MemoryStorage a struct that implements the Storage interface and stores data in the computer's memory.
 type MemoryStorage struct {
 	localMem map[datamodels.UniqueData]datamodels.Data
+	// keys - vaults of users unlocked by Login
+	keys map[uint32]*vault
+	// logins - logins of unlocked users, their vault files are named after them
+	logins map[uint32]string
+	legacy utils.Cipher
 }
 
 // NewMemoryStorage creates a new MemoryStorage instance.
-func NewMemoryStorage() Storage {
+// Users and notes written by old clients are read from the JSON files, others are loaded from vault files on Login.
+func NewMemoryStorage() *MemoryStorage {
 	Users = sessionstorage.Init()
 	var err error
 	Users, err = files.ReadUsers()
@@ -83,37 +119,49 @@ func NewMemoryStorage() Storage {
 	if err != nil {
 		log.Fatalf("error reading data: %v", err)
 	}
-	return &MemoryStorage{localMem: localMem}
+	legacy, err := utils.NewCipher(legacyClientSecret)
+	if err != nil {
+		log.Fatalf("error creating cipher: %v", err)
+	}
+	return &MemoryStorage{localMem: localMem, keys: make(map[uint32]*vault), logins: make(map[uint32]string), legacy: legacy}
 }
 
 // Auth adds a new user.
 // If the user already exists, it returns an error.
 func (ms *MemoryStorage) Auth(login string, password string) error {
 	var header metadata.MD
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	_, err := Client.Auth(context.Background(), &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
-	md = header
+	ctx := outgoingContext()
+	resp, err := Client.Auth(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
+	if err == nil {
+		setSession(header, resp.ExpiresAt)
+	}
 	st := status.Convert(err)
+	if st.Code() == codes.ResourceExhausted {
+		return lockoutError(err)
+	}
 	if st.Err() == nil {
 
-		ctx = metadata.NewOutgoingContext(context.Background(), md)
+		ctx = outgoingContext()
 		id, errClient := Client.Login(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
-		md = header
+		if errClient == nil {
+			setSession(header, id.ExpiresAt)
+		}
 
 		st = status.Convert(errClient)
 		if st.Err() != nil {
 			return st.Err()
 		}
-		passHash := utils.GetMD5Hash(password)
-		err = Users.AddUser(login, passHash, id.Id)
-		if err != nil {
+		if _, ok := Users.GetUser(login); ok {
+			return errors.New("user already exists")
+		}
+		if _, errVault := files.ReadHeader(files.VaultPath(vaultDir, login)); errVault != files.ErrNoVault {
 			return errors.New("user already exists")
 		}
-		wErr := files.WriteUser(datamodels.Auth{ID: id.Id, Login: login, Password: passHash})
-		if wErr != nil {
-			return errors.New("error writing to user file")
+		user, err := newLocalUser(login, password, id.Id)
+		if err != nil {
+			return err
 		}
-		return nil
+		return ms.unlock(login, password, user, id.VaultSalt, id.KeyHistory)
 	}
 	return st.Err()
 }
@@ -121,87 +169,170 @@ func (ms *MemoryStorage) Auth(login string, password string) error {
 // Login verifies the login credentials.
 func (ms *MemoryStorage) Login(login string, password string) (uint32, error) {
 	var header metadata.MD
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	ctx := outgoingContext()
 	id, err := Client.Login(ctx, &pb.AuthLoginRequest{Login: login, Password: password}, grpc.Header(&header))
-	md = header
+	if status.Code(err) == codes.ResourceExhausted {
+		return 0, lockoutError(err)
+	}
+	if err == nil && id.Challenge != "" {
+		// password is right, so a failed second factor must not fall back to offline login
+		id, err = verifySecondFactor(id.Challenge, &header)
+		if err != nil {
+			return 0, lockoutError(err)
+		}
+	}
 	if err == nil {
-		_, ok := Users.GetUser(login)
+		setSession(header, id.ExpiresAt)
+		if err = ms.loadLocal(login, password, id.VaultSalt, id.KeyHistory); err != nil {
+			return 0, err
+		}
+		user, ok := Users.GetUser(login)
 		if !ok {
-			Users.AddUser(login, utils.GetMD5Hash(password), id.Id)
-			wErr := files.WriteUser(datamodels.Auth{ID: id.Id, Login: login, Password: utils.GetMD5Hash(password)})
-			if wErr != nil {
-				return 0, errors.New("error writing to user file")
-			}
+			user, err = newLocalUser(login, password, id.Id)
+		} else {
+			// server accepted the password, so local hash is replaced if it is outdated or was changed on another device
+			user, err = upgradeLocalHash(login, password, user, true)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if err = ms.unlock(login, password, user, id.VaultSalt, id.KeyHistory); err != nil {
+			return 0, err
 		}
 		return id.Id, nil
 	}
+	return ms.LoginOffline(login, password)
+}
+
+// LoginOffline unlocks the local vault with the master password without asking server, nothing is sent over the network.
+func (ms *MemoryStorage) LoginOffline(login string, password string) (uint32, error) {
+	if err := ms.loadLocal(login, password, nil, nil); err != nil {
+		return 0, err
+	}
 	user, ok := Users.GetUser(login)
 	if !ok {
 		return 0, errors.New("user not found")
 	}
-	passHash := utils.GetMD5Hash(password)
-	if user.Password != passHash {
-		return 0, errors.New("wrong password")
+	user, err := upgradeLocalHash(login, password, user, false)
+	if err != nil {
+		return 0, err
+	}
+	if err = ms.unlock(login, password, user, nil, nil); err != nil {
+		return 0, err
 	}
 	return user.ID, nil
 }
 
-// AddData adds data to the storage.
+// AddData adds data to the storage, the note is kept locally even if server can't be reached, then ErrLocalOnly is returned.
+// File uploaded for the replaced record is erased on server once server has the new record.
 func (ms *MemoryStorage) AddData(data datamodels.Data) error {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	Client.AddData(ctx, &pb.AddDataRequest{Data: &pb.Data{DataId: data.DataID, Data: data.Data, MetaInfo: data.Metadata}})
-
-	data.Data = utils.Encrypt(data.Data, clientSecret)
-	data.Metadata = utils.Encrypt(data.Metadata, clientSecret)
-
-	ms.localMem[datamodels.UniqueData{DataID: data.DataID, UserID: data.UserID}] = datamodels.Data{UserID: data.UserID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: time.Now()}
-	err := files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: time.Now()})
+	c, err := ms.cipherFor(data.UserID)
+	if err != nil {
+		return err
+	}
+	data.Deleted = false
+	data.ChangedAt = time.Now()
+	sealed, err := toServer(c, data)
 	if err != nil {
-		return errors.New("err writing data to file")
+		return err
+	}
+	replaced := ms.replacedBlob(c, data)
+	ctx := authContext()
+	_, errServer := Client.AddData(ctx, &pb.AddDataRequest{Data: sealed})
+	if err = ms.storeLocal(c, data); err != nil {
+		return err
+	}
+	if errServer != nil {
+		return fmt.Errorf("%w: %v", ErrLocalOnly, errServer)
+	}
+	if replaced != "" {
+		// the blob stays linked to the note, so it is erased by purge anyway
+		if _, err = Client.DeleteBlob(ctx, &pb.BlobRequest{BlobId: replaced}); err != nil && status.Code(err) != codes.NotFound {
+			log.Printf("previous file of %s wasn't erased on server: %v", data.DataID, err)
+		}
 	}
 	return nil
 }
 
 // DelData deletes data from the storage.
 func (ms *MemoryStorage) DelData(dataID string, userID uint32) error {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	ctx := authContext()
 	Client.DelData(ctx, &pb.GetDataRequest{DataId: dataID})
-	user, _ := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
-	if user.UserID == userID {
-		user.Deleted = true
-		ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = user
+	user, ok := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
+	if !ok {
+		return nil
 	}
-	err := files.WriteData(datamodels.Data{UserID: user.UserID, DataID: user.DataID, Data: user.Data, Metadata: user.Metadata, Deleted: true, ChangedAt: time.Now()})
+	user.Deleted = true
+	user.ChangedAt = time.Now()
+	ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = user
+	return ms.persist(userID)
+}
+
+// ListData returns decrypted notes of the user from the local vault sorted by data id, it works without server.
+func (ms *MemoryStorage) ListData(userID uint32) ([]datamodels.Data, error) {
+	c, err := ms.cipherFor(userID)
 	if err != nil {
-		return errors.New("err writing data to file")
+		return nil, err
 	}
-	return nil
+	var list []datamodels.Data
+	for k, note := range ms.localMem {
+		if k.UserID != userID || note.Deleted {
+			continue
+		}
+		if note, err = openNote(c, note); err != nil {
+			return nil, err
+		}
+		list = append(list, note)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].DataID < list[j].DataID })
+	return list, nil
+}
+
+// LocalData returns decrypted note of the user from the local vault without asking server.
+func (ms *MemoryStorage) LocalData(dataID string, userID uint32) (datamodels.Data, error) {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return datamodels.Data{}, err
+	}
+	note, ok := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
+	if !ok || note.Deleted {
+		return datamodels.Data{}, ErrNotFound
+	}
+	return openNote(c, note)
 }
 
 // GetData retrieves data from the storage.
 func (ms *MemoryStorage) GetData(dataID string, userID uint32) (datamodels.Data, error) {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	c, errKey := ms.cipherFor(userID)
+	if errKey != nil {
+		return datamodels.Data{}, errKey
+	}
+	ctx := authContext()
 	resp, err := Client.GetData(ctx, &pb.GetDataRequest{DataId: dataID})
 	var response datamodels.Data
 	if err == nil {
-		response = datamodels.Data{DataID: resp.Data.DataId, Data: resp.Data.Data, UserID: userID, Metadata: resp.Data.MetaInfo}
+		response, err = fromServer(c, userID, resp.Data)
 	}
 
 	data, ok := ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}]
 	if !ok || data.Deleted {
 		if err == nil {
-			ms.localMem[datamodels.UniqueData{DataID: dataID, UserID: userID}] = response
-			errF := files.WriteData(response)
-			if errF != nil {
-				return datamodels.Data{}, errors.New("err writing data to file")
+			if errF := ms.storeLocal(c, response); errF != nil {
+				return datamodels.Data{}, errF
 			}
 			return response, nil
 		}
 		return datamodels.Data{}, errors.New("no data found")
 	}
 	if data.UserID == userID && !data.Deleted {
-		data.Data = utils.Decrypt(data.Data, clientSecret)
-		data.Metadata = utils.Decrypt(data.Metadata, clientSecret)
+		var errOpen error
+		data, errOpen = openNote(c, data)
+		if errOpen != nil {
+			if err == nil {
+				return response, nil
+			}
+			return datamodels.Data{}, errOpen
+		}
 	}
 	if err == nil && data.ChangedAt.Before(response.ChangedAt) {
 		return response, nil
@@ -210,50 +341,108 @@ func (ms *MemoryStorage) GetData(dataID string, userID uint32) (datamodels.Data,
 }
 
 // Sync synchronizes data from server for a specific user.
+// Notes still encrypted with the server key or with a key of a previous password are sent back as end-to-end envelopes of the current key.
 func (ms *MemoryStorage) Sync(userId uint32) ([]datamodels.Data, error) {
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	c, err := ms.cipherFor(userId)
+	if err != nil {
+		return nil, err
+	}
+	ctx := authContext()
 	resp, err := Client.Sync(ctx, &emptypb.Empty{})
 	if err != nil {
 		return nil, err
 	}
 	var response []datamodels.Data
+	var migrated []*pb.Data
+	onServer := make(map[string]bool, len(resp.Data))
 	for _, v := range resp.Data {
-		data, ok := ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}]
-		if !ok {
-			response = append(response, datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
-			v.Data = utils.Encrypt(v.Data, clientSecret)
-			v.MetaInfo = utils.Encrypt(v.MetaInfo, clientSecret)
-			ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}] = datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
-			err = files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: v.ChangedAt.AsTime()})
-			if err != nil {
-				return nil, errors.New("err writing data to file")
+		onServer[v.DataId] = true
+		key := datamodels.UniqueData{DataID: v.DataId, UserID: userId}
+		if v.Purged {
+			// erased on another device, a note created here later is kept
+			if data, ok := ms.localMem[key]; ok && !data.ChangedAt.After(v.ChangedAt.AsTime()) {
+				delete(ms.localMem, key)
 			}
-		} else if data.ChangedAt.Before(v.ChangedAt.AsTime()) {
-			response = append(response, datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()})
-			v.Data = utils.Encrypt(v.Data, clientSecret)
-			v.MetaInfo = utils.Encrypt(v.MetaInfo, clientSecret)
-			ms.localMem[datamodels.UniqueData{DataID: v.DataId, UserID: userId}] = datamodels.Data{DataID: v.DataId, Data: v.Data, UserID: userId, Metadata: v.MetaInfo, Deleted: v.Deleted, ChangedAt: v.ChangedAt.AsTime()}
-			err = files.WriteData(datamodels.Data{UserID: data.UserID, DataID: data.DataID, Data: data.Data, Metadata: data.Metadata, Deleted: false, ChangedAt: v.ChangedAt.AsTime()})
-			if err != nil {
-				return nil, errors.New("err writing data to file")
+			continue
+		}
+		note, sealed, err := migrateNote(c, userId, v)
+		if err != nil {
+			return nil, err
+		}
+		if sealed != nil {
+			migrated = append(migrated, sealed)
+		}
+		data, ok := ms.localMem[key]
+		if !ok || data.ChangedAt.Before(note.ChangedAt) {
+			response = append(response, note)
+			if err = ms.putLocal(c, note); err != nil {
+				return nil, err
 			}
 		}
 	}
+	for k, v := range ms.localMem {
+		// server removed old tombstones, they are not needed here either
+		if k.UserID == userId && v.Deleted && !onServer[k.DataID] {
+			delete(ms.localMem, k)
+		}
+	}
+	if err = ms.persist(userId); err != nil {
+		return nil, err
+	}
+	if len(migrated) > 0 {
+		if _, err = Client.ClientSync(ctx, &pb.ClientSyncRequest{Data: migrated}); err != nil {
+			return nil, err
+		}
+	}
 	return response, nil
 }
 
+// storeLocal - encrypts note and saves it to the vault file
+func (ms *MemoryStorage) storeLocal(c utils.Cipher, note datamodels.Data) error {
+	if err := ms.putLocal(c, note); err != nil {
+		return err
+	}
+	return ms.persist(note.UserID)
+}
+
+// putLocal - encrypts note and keeps it in memory until the vault file is written
+func (ms *MemoryStorage) putLocal(c utils.Cipher, note datamodels.Data) error {
+	meta, err := packMeta(note)
+	if err != nil {
+		return err
+	}
+	note.Data, note.Metadata, err = sealPair(c, note.Data, meta)
+	if err != nil {
+		return ErrInternal
+	}
+	note.DataBlob, note.MetaBlob, note.KeyID = nil, nil, ""
+	note.Fields, note.Tags = nil, nil
+	ms.localMem[datamodels.UniqueData{DataID: note.DataID, UserID: note.UserID}] = note
+	return nil
+}
+
 // ClientSync - synchronize client data with server
 func (ms *MemoryStorage) ClientSync(userID uint32, data []*pb.Data) error {
+	c, err := ms.cipherFor(userID)
+	if err != nil {
+		return err
+	}
 	var req []*pb.Data
 	for k, v := range ms.localMem {
 		if k.UserID == userID {
-			v.Data = utils.Decrypt(v.Data, clientSecret)
-			v.Metadata = utils.Decrypt(v.Metadata, clientSecret)
-			req = append(req, &pb.Data{Data: v.Data, DataId: v.DataID, MetaInfo: v.Metadata, Deleted: v.Deleted, ChangedAt: timestamppb.New(v.ChangedAt)})
+			v.Data, v.Metadata, err = openPair(c, v.Data, v.Metadata)
+			if err != nil {
+				return err
+			}
+			sealed, err := toServer(c, v)
+			if err != nil {
+				return err
+			}
+			req = append(req, sealed)
 		}
 	}
-	ctx := metadata.NewOutgoingContext(context.Background(), md)
-	_, err := Client.ClientSync(ctx, &pb.ClientSyncRequest{Data: req})
+	ctx := authContext()
+	_, err = Client.ClientSync(ctx, &pb.ClientSyncRequest{Data: req})
 	if err != nil {
 		return err
 	}
@@ -4,35 +4,77 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/datamodels"
 )
 
+// testClient - connection to local plaintext server
+var testClient = config.Client{Address: ":3200", Insecure: true}
+
+// initTest - connects to the test server, vault files are written to a temporary directory
+func initTest(t *testing.T) error {
+	cfg := testClient
+	cfg.VaultDir = t.TempDir()
+	return Init(cfg)
+}
+
 func TestMemoryStorage_Login(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
+	assert.NoError(t, initTest(t))
 	id, err := s.Login("final", "1")
 	assert.NoError(t, err)
 	assert.NotNil(t, id)
 }
+// loginTestUser - creates local user if needed and unlocks its vault
+func loginTestUser(t *testing.T, s Storage) {
+	if _, ok := Users.GetUser("test"); !ok {
+		_, err := newLocalUser("test", "password", 0)
+		assert.NoError(t, err)
+	}
+	_, err := s.Login("test", "password")
+	assert.NoError(t, err)
+}
 func TestMemoryStorage_AddData(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
+	assert.NoError(t, initTest(t))
+	loginTestUser(t, s)
 	err := s.AddData(datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
-	assert.NoError(t, err)
+	// without server the note is kept locally and the error says so
+	if err != nil {
+		assert.ErrorIs(t, err, ErrLocalOnly)
+	}
 
 }
 func TestMemoryStorage_DelData(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
+	assert.NoError(t, initTest(t))
 	err := s.DelData("new", 0)
 	assert.NoError(t, err)
 }
 func TestMemoryStorage_Get(t *testing.T) {
 	s := NewMemoryStorage()
-	Init()
+	assert.NoError(t, initTest(t))
+	loginTestUser(t, s)
 	err := s.AddData(datamodels.Data{DataID: "new", Data: "test", Metadata: "test"})
-	assert.NoError(t, err)
+	// without server the note is kept locally and the error says so
+	if err != nil {
+		assert.ErrorIs(t, err, ErrLocalOnly)
+	}
 	data, err := s.GetData("new", 0)
 	assert.NoError(t, err)
 	assert.NotNil(t, data)
 }
+func TestMemoryStorage_LoginOffline(t *testing.T) {
+	s := NewMemoryStorage()
+	assert.NoError(t, initTest(t))
+	loginTestUser(t, s)
+	// no client, so any call to server would fail the test
+	client := Client
+	Client = nil
+	defer func() { Client = client }()
+	id, err := s.LoginOffline("test", "password")
+	assert.NoError(t, err)
+	assert.Zero(t, id)
+	_, err = s.LoginOffline("test", "wrong")
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+// Package storage provides implementations for data storage functions.
+package storage
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// refreshMargin - session token is refreshed when it expires sooner than this
+const refreshMargin = time.Minute
+
+// mdExpires - time when the session token in md expires
+var mdExpires time.Time
+
+// device - name of this client sent with every call, server saves it with sessions
+var device string
+
+// outgoingContext - returns context with the session token and device name
+func outgoingContext() context.Context {
+	return metadata.NewOutgoingContext(context.Background(), metadata.Join(md, metadata.Pairs("device", device)))
+}
+
+// setSession - saves session token received in header and its expiration time
+func setSession(header metadata.MD, expiresAt *timestamppb.Timestamp) {
+	token := header.Get("userid")
+	if len(token) == 0 {
+		return
+	}
+	md = metadata.Pairs("userid", token[0])
+	mdExpires = expiresAt.AsTime()
+}
+
+// authContext - returns outgoing context with the session token, the token is refreshed before it expires
+func authContext() context.Context {
+	ctx := outgoingContext()
+	if len(md.Get("userid")) == 0 || time.Until(mdExpires) > refreshMargin {
+		return ctx
+	}
+	var header metadata.MD
+	resp, err := Client.Refresh(ctx, &emptypb.Empty{}, grpc.Header(&header))
+	if err != nil {
+		// expired token is rejected by server anyway, offline work goes on with local data
+		return ctx
+	}
+	setSession(header, resp.ExpiresAt)
+	return outgoingContext()
+}
+
+// Logout ends server session and locks vaults unlocked by Login.
+func (ms *MemoryStorage) Logout() error {
+	if len(md.Get("userid")) > 0 {
+		ctx := outgoingContext()
+		if _, err := Client.Logout(ctx, &emptypb.Empty{}); err != nil {
+			return err
+		}
+	}
+	md, mdExpires = nil, time.Time{}
+	for id := range ms.keys {
+		delete(ms.keys, id)
+	}
+	return nil
+}
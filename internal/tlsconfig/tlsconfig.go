@@ -0,0 +1,117 @@
+// Package tlsconfig builds TLS configurations of the gRPC server and client.
+package tlsconfig
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+)
+
+// Module errors
+var (
+	ErrVersion = errors.New("unsupported TLS version")
+	ErrNoCerts = errors.New("no certificates found in CA file")
+	ErrPin     = errors.New("server certificate does not match pinned key")
+	ErrNoCert  = errors.New("certificate and key files are required")
+)
+
+// ParseVersion - converts "1.2" or "1.3" to TLS version, empty string means TLS 1.2
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, ErrVersion
+}
+
+// Server returns configuration of the server certificate.
+// When clientCA is set the server works in mutual TLS mode: only clients with certificates issued by clientCA are accepted.
+func Server(certFile string, keyFile string, minVersion string, clientCA string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, ErrNoCert
+	}
+	version, err := ParseVersion(minVersion)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: version}
+	if clientCA != "" {
+		cfg.ClientCAs, err = loadPool(clientCA)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
+
+// Client returns configuration of the client.
+// caFile replaces system roots, pin is base64 SHA-256 of the server public key (SubjectPublicKeyInfo),
+// certFile and keyFile are the client certificate for mutual TLS. Empty values are not used.
+func Client(caFile string, pin string, certFile string, keyFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	var err error
+	if caFile != "" {
+		cfg.RootCAs, err = loadPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, ErrNoCert
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if pin != "" {
+		expected, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+		if err != nil || len(expected) != sha256.Size {
+			return nil, ErrPin
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return ErrPin
+			}
+			actual := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if subtle.ConstantTimeCompare(actual[:], expected) != 1 {
+				return ErrPin
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+// Pin returns pin of the certificate public key in the format accepted by Client.
+func Pin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// loadPool - reads PEM certificates from the file
+func loadPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, ErrNoCerts
+	}
+	return pool, nil
+}
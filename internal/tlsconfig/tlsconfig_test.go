@@ -0,0 +1,168 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA - certificate authority issuing test certificates
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	file string
+}
+
+func newTestCA(t *testing.T, dir string, name string) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	file := filepath.Join(dir, name+".pem")
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	return testCA{cert: cert, key: key, file: file}
+}
+
+// issue - writes certificate and key signed by the CA, returns their files and certificate
+func (ca testCA) issue(t *testing.T, dir string, name string, usage x509.ExtKeyUsage) (string, string, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return certFile, keyFile, cert
+}
+
+// handshake - connects client and server configurations over loopback
+func handshake(t *testing.T, server *tls.Config, client *tls.Config) error {
+	listen, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listen.Close()
+	done := make(chan error, 1)
+	go func() {
+		conn, err := listen.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer conn.Close()
+		done <- tls.Server(conn, server).Handshake()
+	}()
+	client = client.Clone()
+	client.ServerName = "localhost"
+	conn, err := tls.Dial("tcp", listen.Addr().String(), client)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// client certificate is verified after the client finished TLS 1.3 handshake
+	return <-done
+}
+
+func TestParseVersion(t *testing.T) {
+	v, err := ParseVersion("")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), v)
+	v, err = ParseVersion("1.3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), v)
+	_, err = ParseVersion("1.0")
+	assert.ErrorIs(t, err, ErrVersion)
+}
+
+func TestHandshake(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	clientCA := newTestCA(t, dir, "client-ca")
+	otherCA := newTestCA(t, dir, "other-ca")
+	serverCert, serverKey, cert := ca.issue(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey, _ := clientCA.issue(t, dir, "client", x509.ExtKeyUsageClientAuth)
+	strangerCert, strangerKey, _ := otherCA.issue(t, dir, "stranger", x509.ExtKeyUsageClientAuth)
+
+	server, err := Server(serverCert, serverKey, "1.2", "")
+	require.NoError(t, err)
+	mutual, err := Server(serverCert, serverKey, "1.3", clientCA.file)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		server  *tls.Config
+		ca      string
+		pin     string
+		cert    string
+		key     string
+		wantErr bool
+	}{
+		{name: "trusted CA", server: server, ca: ca.file},
+		{name: "unknown CA", server: server, ca: otherCA.file, wantErr: true},
+		{name: "matching pin", server: server, ca: ca.file, pin: Pin(cert)},
+		{name: "wrong pin", server: server, ca: ca.file, pin: Pin(ca.cert), wantErr: true},
+		{name: "enrolled client", server: mutual, ca: ca.file, cert: clientCert, key: clientKey},
+		{name: "no client certificate", server: mutual, ca: ca.file, wantErr: true},
+		{name: "client not enrolled", server: mutual, ca: ca.file, cert: strangerCert, key: strangerKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := Client(tt.ca, tt.pin, tt.cert, tt.key)
+			require.NoError(t, err)
+			err = handshake(t, tt.server, client)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	_, err := Server("", "", "", "")
+	assert.ErrorIs(t, err, ErrNoCert)
+	_, err = Client("", "not a pin", "", "")
+	assert.ErrorIs(t, err, ErrPin)
+	_, err = Client("", "", "client.crt", "")
+	assert.ErrorIs(t, err, ErrNoCert)
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	assert.NoError(t, os.WriteFile(empty, nil, 0600))
+	_, err = Client(empty, "", "", "")
+	assert.ErrorIs(t, err, ErrNoCerts)
+}
@@ -4,52 +4,149 @@ package utils
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
 
-	"log"
+// Envelope layout: version (1 byte) | key id (4 bytes, big endian) | nonce | ciphertext.
+const (
+	envelopeVersion byte = 1
+	keyIDSize            = 4
+	headerSize           = 1 + keyIDSize
 )
 
-var nonce = []byte{156, 123, 210, 167, 214, 230, 92, 233, 232, 233, 172, 192}
+// ErrCorrupted - returned when ciphertext can't be decoded or fails authentication
+var ErrCorrupted = errors.New("ciphertext corrupted")
 
-// Encrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated encryption.
-func Encrypt(text string, key []byte) string {
+// legacyNonce - fixed nonce used by the first ciphertext format, kept only for decryption
+var legacyNonce = []byte{156, 123, 210, 167, 214, 230, 92, 233, 232, 233, 172, 192}
+
+// Cipher - authenticated encryption used for every stored value
+type Cipher interface {
+	// Seal encrypts plaintext into a versioned envelope
+	Seal(plaintext []byte) ([]byte, error)
+	// Open decrypts envelope created by Seal, it returns ErrCorrupted if envelope was tampered
+	Open(envelope []byte) ([]byte, error)
+	// KeyID returns id of the key written into sealed envelopes
+	KeyID() uint32
+}
+
+// aesCipher - AES-GCM implementation of Cipher
+type aesCipher struct {
+	aead  cipher.AEAD
+	keyID uint32
+}
+
+// NewCipher creates AES-GCM Cipher for the key, key must be 16, 24 or 32 bytes long.
+func NewCipher(key []byte) (Cipher, error) {
 	// Generate a new AES cipher block using the secret key
 	block, err := aes.NewCipher(key)
 	if err != nil {
-		log.Fatalf("aes %v", err)
+		return nil, err
 	}
 	// Create a new GCM (Galois/Counter Mode) cipher using the AES block
 	// GCM provides authenticated encryption
-	aesGCM, err := cipher.NewGCM(block)
+	aead, err := cipher.NewGCM(block)
 	if err != nil {
-		log.Fatalf("GCM %v", err)
+		return nil, err
 	}
-	// Encrypt the plaintext using AES-GCM
-	ciphertext := aesGCM.Seal(nil, nonce, []byte(text), nil)
-	return base64.RawStdEncoding.EncodeToString(ciphertext)
+	return &aesCipher{aead: aead, keyID: KeyID(key)}, nil
 }
 
-// Decrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated decryption.
-func Decrypt(text string, key []byte) string {
-	// Generate a new AES cipher block using the secret key
-	decodedCiphertext, err := base64.RawStdEncoding.DecodeString(text)
+// KeyID - returns short fingerprint of the key which is written into every envelope
+func KeyID(key []byte) uint32 {
+	sum := sha256.Sum256(key)
+	return binary.BigEndian.Uint32(sum[:keyIDSize])
+}
+
+// KeyID returns fingerprint of the cipher key.
+func (c *aesCipher) KeyID() uint32 {
+	return c.keyID
+}
+
+// Seal encrypts plaintext with a fresh random nonce, header is authenticated as additional data.
+func (c *aesCipher) Seal(plaintext []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	envelope := make([]byte, headerSize+nonceSize, headerSize+nonceSize+len(plaintext)+c.aead.Overhead())
+	envelope[0] = envelopeVersion
+	binary.BigEndian.PutUint32(envelope[1:headerSize], c.keyID)
+	nonce := envelope[headerSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(envelope, nonce, plaintext, envelope[:headerSize]), nil
+}
+
+// Open decrypts envelope, values written with the legacy fixed nonce are accepted too.
+func (c *aesCipher) Open(envelope []byte) ([]byte, error) {
+	if decrypted, ok := c.openEnvelope(envelope); ok {
+		return decrypted, nil
+	}
+	decrypted, err := c.aead.Open(nil, legacyNonce, envelope, nil)
 	if err != nil {
-		log.Fatalf("decrypt 1 %v", err)
+		return nil, ErrCorrupted
 	}
-	block, err := aes.NewCipher(key)
+	return decrypted, nil
+}
+
+// openEnvelope - decrypts versioned envelope, ok is false when data is not an envelope for this key
+func (c *aesCipher) openEnvelope(envelope []byte) ([]byte, bool) {
+	nonceSize := c.aead.NonceSize()
+	if len(envelope) < headerSize+nonceSize+c.aead.Overhead() || envelope[0] != envelopeVersion {
+		return nil, false
+	}
+	if binary.BigEndian.Uint32(envelope[1:headerSize]) != c.keyID {
+		return nil, false
+	}
+	nonce := envelope[headerSize : headerSize+nonceSize]
+	decrypted, err := c.aead.Open(nil, nonce, envelope[headerSize+nonceSize:], envelope[:headerSize])
 	if err != nil {
-		log.Fatal(err)
+		return nil, false
 	}
-	// Create a new GCM (Galois/Counter Mode) cipher using the AES block
-	// GCM provides authenticated encryption
-	aesGCM, err := cipher.NewGCM(block)
+	return decrypted, true
+}
+
+// SealString - seals text and encodes envelope with base64
+func SealString(c Cipher, text string) (string, error) {
+	envelope, err := c.Seal([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(envelope), nil
+}
+
+// OpenString - decodes base64 envelope created by SealString and opens it
+func OpenString(c Cipher, text string) (string, error) {
+	envelope, err := base64.RawStdEncoding.DecodeString(text)
 	if err != nil {
-		log.Fatalf("decrypt 2 %v", err)
+		return "", ErrCorrupted
 	}
-	// Decrypt the ciphertext using AES-GCM
-	decrypted, err := aesGCM.Open(nil, nonce, decodedCiphertext, nil)
+	decrypted, err := c.Open(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(decrypted), nil
+}
+
+// Encrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated encryption.
+// Every call uses a fresh random nonce which is stored in the envelope together with the key id.
+func Encrypt(text string, key []byte) (string, error) {
+	c, err := NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	return SealString(c, text)
+}
+
+// Decrypt - use the AES cipher in Galois/Counter Mode (GCM) to perform authenticated decryption.
+// Envelopes written by Encrypt and values written with the legacy fixed nonce are both accepted.
+func Decrypt(text string, key []byte) (string, error) {
+	c, err := NewCipher(key)
 	if err != nil {
-		log.Printf("decrypt 3 %v", err)
+		return "", err
 	}
-	return string(decrypted)
+	return OpenString(c, text)
 }
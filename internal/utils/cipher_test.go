@@ -1,14 +1,62 @@
 package utils
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"log"
+)
 
 var mysecret = "qwertyuiopmmasdf"
 
-func ExampleEncryptDecrypt() {
-	str := Encrypt("example string", []byte(mysecret))
-	str = Decrypt(str, []byte(mysecret))
+func ExampleEncrypt() {
+	str, err := Encrypt("example string", []byte(mysecret))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	str, err = Decrypt(str, []byte(mysecret))
+	if err != nil {
+		log.Fatalln(err)
+	}
 	fmt.Println(str)
 	// Output:
 	//example string
 
 }
+
+func ExampleEncrypt_nonce() {
+	first, _ := Encrypt("example string", []byte(mysecret))
+	second, _ := Encrypt("example string", []byte(mysecret))
+	fmt.Println(first == second)
+	// Output:
+	//false
+}
+
+func ExampleDecrypt_legacy() {
+	// value written to data.json with the fixed nonce format
+	str, err := Decrypt("xjfKLoBTuS6M+36cy+cvpL4TiFC1SL7LwzwcWfqZ6BX+N7r8ZQ", []byte("qpwoeritkvndgahz"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(str)
+	// Output:
+	//111010101010101010101
+}
+
+func ExampleCipher_Open() {
+	c, err := NewCipher([]byte(mysecret))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	envelope, err := c.Seal([]byte("example string"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	envelope[len(envelope)-1] ^= 1
+	_, err = c.Open(envelope)
+	fmt.Println(errors.Is(err, ErrCorrupted))
+	_, err = NewCipher([]byte("short"))
+	fmt.Println(err != nil)
+	// Output:
+	//true
+	//true
+}
@@ -0,0 +1,47 @@
+// Package utils provides utility functions
+package utils
+
+import (
+	"crypto/rand"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters used to derive vault keys from the master password
+const (
+	kdfTime    uint32 = 1
+	kdfMemory  uint32 = 64 * 1024
+	kdfThreads uint8  = 4
+	kdfKeyLen  uint32 = 32
+	saltLen           = 16
+)
+
+// NewSalt - generates random salt for key derivation
+func NewSalt() ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// KDFParams - Argon2id cost parameters, they are saved with the salt so keys can be derived after defaults change
+type KDFParams struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	KeyLen  uint32 `json:"key_len"`
+}
+
+// DefaultKDF - parameters used to derive vault keys
+var DefaultKDF = KDFParams{Time: kdfTime, Memory: kdfMemory, Threads: kdfThreads, KeyLen: kdfKeyLen}
+
+// DeriveKey - derives 256-bit encryption key from the password with Argon2id
+func DeriveKey(password string, salt []byte) []byte {
+	return DeriveKeyParams(password, salt, DefaultKDF)
+}
+
+// DeriveKeyParams - derives key from the password with Argon2id of the given cost
+func DeriveKeyParams(password string, salt []byte, p KDFParams) []byte {
+	return argon2.IDKey([]byte(password), salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+}
@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+)
+
+func ExampleDeriveKey() {
+	salt, err := NewSalt()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	key := DeriveKey("password", salt)
+	fmt.Println(len(key))
+	fmt.Println(bytes.Equal(key, DeriveKey("password", salt)))
+	fmt.Println(bytes.Equal(key, DeriveKey("another", salt)))
+	//Output:
+	//32
+	//true
+	//false
+}
@@ -0,0 +1,66 @@
+// Package utils provides utility functions
+package utils
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2id parameters of new password hashes
+const (
+	passwordTime    uint32 = 1
+	passwordMemory  uint32 = 64 * 1024
+	passwordThreads uint8  = 4
+	passwordKeyLen  uint32 = 32
+)
+
+// HashPassword - returns Argon2id hash of the password in PHC string format
+func HashPassword(password string) (string, error) {
+	salt, err := NewSalt()
+	if err != nil {
+		return "", err
+	}
+	hash := argon2.IDKey([]byte(password), salt, passwordTime, passwordMemory, passwordThreads, passwordKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, passwordMemory, passwordTime, passwordThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// VerifyPassword - compares password with PHC Argon2id hash or legacy MD5 hash in constant time.
+// rehash is true when the password matches but its hash should be replaced with HashPassword result.
+func VerifyPassword(password string, encoded string) (ok bool, rehash bool) {
+	if !strings.HasPrefix(encoded, "$") {
+		legacy := GetMD5Hash(password)
+		return subtle.ConstantTimeCompare([]byte(legacy), []byte(strings.ToLower(encoded))) == 1, true
+	}
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return false, false
+	}
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(hash)))
+	if subtle.ConstantTimeCompare(actual, hash) != 1 {
+		return false, false
+	}
+	rehash = memory != passwordMemory || time != passwordTime || threads != passwordThreads || uint32(len(hash)) != passwordKeyLen
+	return true, rehash
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+func ExampleHashPassword() {
+	hash, err := HashPassword("password")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=1,p=4$"))
+	fmt.Println(VerifyPassword("password", hash))
+	fmt.Println(VerifyPassword("wrong", hash))
+	//Output:
+	//true
+	//true false
+	//false false
+}
+
+func ExampleVerifyPassword_legacy() {
+	// MD5 hashes written before Argon2id should be upgraded after successful login
+	fmt.Println(VerifyPassword("test", "098f6bcd4621d373cade4e832627b4f6"))
+	fmt.Println(VerifyPassword("wrong", "098f6bcd4621d373cade4e832627b4f6"))
+	//Output:
+	//true true
+	//false true
+}
@@ -5,9 +5,13 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base32"
+	"encoding/base64"
 	"encoding/hex"
 )
 
+// tokenLen - amount of random bytes in session token
+const tokenLen = 32
+
 // GenerateRandomString - generates random string
 func GenerateRandomString(len int) string {
 	b := make([]byte, len)
@@ -15,6 +19,15 @@ func GenerateRandomString(len int) string {
 	return base32.StdEncoding.EncodeToString(b)
 }
 
+// GenerateToken - generates url safe session token with 256 bits of randomness
+func GenerateToken() (string, error) {
+	b := make([]byte, tokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // GetMD5Hash - makes hash of string
 func GetMD5Hash(text string) string {
 	hash := md5.Sum([]byte(text))
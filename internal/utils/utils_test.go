@@ -1,6 +1,9 @@
 package utils
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+)
 
 func ExampleGenerateRandomString() {
 	str := GenerateRandomString(5)
@@ -8,6 +11,15 @@ func ExampleGenerateRandomString() {
 	//Output:
 	//8
 }
+func ExampleGenerateToken() {
+	token, err := GenerateToken()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	fmt.Println(len(token))
+	//Output:
+	//43
+}
 func ExampleGetMD5Hash() {
 	hash := GetMD5Hash("test")
 	fmt.Println(hash)
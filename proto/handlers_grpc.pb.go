@@ -21,13 +21,32 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Gophkeeper_Login_FullMethodName      = "/gophkeeper.Gophkeeper/Login"
-	Gophkeeper_Auth_FullMethodName       = "/gophkeeper.Gophkeeper/Auth"
-	Gophkeeper_AddData_FullMethodName    = "/gophkeeper.Gophkeeper/AddData"
-	Gophkeeper_GetData_FullMethodName    = "/gophkeeper.Gophkeeper/GetData"
-	Gophkeeper_Sync_FullMethodName       = "/gophkeeper.Gophkeeper/Sync"
-	Gophkeeper_ClientSync_FullMethodName = "/gophkeeper.Gophkeeper/ClientSync"
-	Gophkeeper_DelData_FullMethodName    = "/gophkeeper.Gophkeeper/DelData"
+	Gophkeeper_Login_FullMethodName              = "/gophkeeper.Gophkeeper/Login"
+	Gophkeeper_Auth_FullMethodName               = "/gophkeeper.Gophkeeper/Auth"
+	Gophkeeper_AddData_FullMethodName            = "/gophkeeper.Gophkeeper/AddData"
+	Gophkeeper_GetData_FullMethodName            = "/gophkeeper.Gophkeeper/GetData"
+	Gophkeeper_Sync_FullMethodName               = "/gophkeeper.Gophkeeper/Sync"
+	Gophkeeper_ClientSync_FullMethodName         = "/gophkeeper.Gophkeeper/ClientSync"
+	Gophkeeper_DelData_FullMethodName            = "/gophkeeper.Gophkeeper/DelData"
+	Gophkeeper_Refresh_FullMethodName            = "/gophkeeper.Gophkeeper/Refresh"
+	Gophkeeper_Logout_FullMethodName             = "/gophkeeper.Gophkeeper/Logout"
+	Gophkeeper_VerifySecondFactor_FullMethodName = "/gophkeeper.Gophkeeper/VerifySecondFactor"
+	Gophkeeper_EnrollTOTP_FullMethodName         = "/gophkeeper.Gophkeeper/EnrollTOTP"
+	Gophkeeper_ConfirmTOTP_FullMethodName        = "/gophkeeper.Gophkeeper/ConfirmTOTP"
+	Gophkeeper_DisableTOTP_FullMethodName        = "/gophkeeper.Gophkeeper/DisableTOTP"
+	Gophkeeper_UnlockAccount_FullMethodName      = "/gophkeeper.Gophkeeper/UnlockAccount"
+	Gophkeeper_ListAuditEvents_FullMethodName    = "/gophkeeper.Gophkeeper/ListAuditEvents"
+	Gophkeeper_ChangePassword_FullMethodName     = "/gophkeeper.Gophkeeper/ChangePassword"
+	Gophkeeper_UploadBlob_FullMethodName         = "/gophkeeper.Gophkeeper/UploadBlob"
+	Gophkeeper_DownloadBlob_FullMethodName       = "/gophkeeper.Gophkeeper/DownloadBlob"
+	Gophkeeper_UploadStatus_FullMethodName       = "/gophkeeper.Gophkeeper/UploadStatus"
+	Gophkeeper_DeleteBlob_FullMethodName         = "/gophkeeper.Gophkeeper/DeleteBlob"
+	Gophkeeper_ListRevisions_FullMethodName      = "/gophkeeper.Gophkeeper/ListRevisions"
+	Gophkeeper_GetRevision_FullMethodName        = "/gophkeeper.Gophkeeper/GetRevision"
+	Gophkeeper_RestoreRevision_FullMethodName    = "/gophkeeper.Gophkeeper/RestoreRevision"
+	Gophkeeper_ListDeleted_FullMethodName        = "/gophkeeper.Gophkeeper/ListDeleted"
+	Gophkeeper_Undelete_FullMethodName           = "/gophkeeper.Gophkeeper/Undelete"
+	Gophkeeper_Purge_FullMethodName              = "/gophkeeper.Gophkeeper/Purge"
 )
 
 // GophkeeperClient is the client API for Gophkeeper service.
@@ -41,6 +60,32 @@ type GophkeeperClient interface {
 	Sync(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SynchronizationResponse, error)
 	ClientSync(ctx context.Context, in *ClientSyncRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	DelData(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Refresh(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SessionResponse, error)
+	Logout(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	VerifySecondFactor(ctx context.Context, in *SecondFactorRequest, opts ...grpc.CallOption) (*AuthLoginResponse, error)
+	EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error)
+	ConfirmTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*ConfirmTOTPResponse, error)
+	DisableTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// UnlockAccount requires admin-token metadata
+	UnlockAccount(ctx context.Context, in *UnlockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	// ChangePassword revokes all other sessions of the user
+	ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UploadBlob(ctx context.Context, opts ...grpc.CallOption) (Gophkeeper_UploadBlobClient, error)
+	DownloadBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (Gophkeeper_DownloadBlobClient, error)
+	// UploadStatus tells how to resume an interrupted upload
+	UploadStatus(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (*UploadStatusResponse, error)
+	// DeleteBlob erases blob which the record of its note doesn't reference anymore
+	DeleteBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListRevisions(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error)
+	GetRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*GetDataResponse, error)
+	// RestoreRevision makes the revision the current version of the note, the replaced version becomes a revision too
+	RestoreRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListDeleted returns notes in the trash
+	ListDeleted(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SynchronizationResponse, error)
+	Undelete(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Purge erases notes in the trash with their revisions
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type gophkeeperClient struct {
@@ -114,6 +159,225 @@ func (c *gophkeeperClient) DelData(ctx context.Context, in *GetDataRequest, opts
 	return out, nil
 }
 
+func (c *gophkeeperClient) Refresh(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SessionResponse, error) {
+	out := new(SessionResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_Refresh_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) Logout(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_Logout_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) VerifySecondFactor(ctx context.Context, in *SecondFactorRequest, opts ...grpc.CallOption) (*AuthLoginResponse, error) {
+	out := new(AuthLoginResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_VerifySecondFactor_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error) {
+	out := new(EnrollTOTPResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_EnrollTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ConfirmTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*ConfirmTOTPResponse, error) {
+	out := new(ConfirmTOTPResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ConfirmTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) DisableTOTP(ctx context.Context, in *TOTPCodeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_DisableTOTP_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) UnlockAccount(ctx context.Context, in *UnlockRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_UnlockAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListAuditEvents(ctx context.Context, in *ListAuditEventsRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	out := new(ListAuditEventsResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListAuditEvents_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ChangePassword(ctx context.Context, in *ChangePasswordRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_ChangePassword_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) UploadBlob(ctx context.Context, opts ...grpc.CallOption) (Gophkeeper_UploadBlobClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Gophkeeper_ServiceDesc.Streams[0], Gophkeeper_UploadBlob_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gophkeeperUploadBlobClient{stream}
+	return x, nil
+}
+
+type Gophkeeper_UploadBlobClient interface {
+	Send(*BlobChunk) error
+	CloseAndRecv() (*UploadBlobResponse, error)
+	grpc.ClientStream
+}
+
+type gophkeeperUploadBlobClient struct {
+	grpc.ClientStream
+}
+
+func (x *gophkeeperUploadBlobClient) Send(m *BlobChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *gophkeeperUploadBlobClient) CloseAndRecv() (*UploadBlobResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadBlobResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gophkeeperClient) DownloadBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (Gophkeeper_DownloadBlobClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Gophkeeper_ServiceDesc.Streams[1], Gophkeeper_DownloadBlob_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &gophkeeperDownloadBlobClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Gophkeeper_DownloadBlobClient interface {
+	Recv() (*BlobChunk, error)
+	grpc.ClientStream
+}
+
+type gophkeeperDownloadBlobClient struct {
+	grpc.ClientStream
+}
+
+func (x *gophkeeperDownloadBlobClient) Recv() (*BlobChunk, error) {
+	m := new(BlobChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *gophkeeperClient) UploadStatus(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (*UploadStatusResponse, error) {
+	out := new(UploadStatusResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_UploadStatus_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) DeleteBlob(ctx context.Context, in *BlobRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_DeleteBlob_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListRevisions(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*ListRevisionsResponse, error) {
+	out := new(ListRevisionsResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListRevisions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) GetRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*GetDataResponse, error) {
+	out := new(GetDataResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_GetRevision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) RestoreRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_RestoreRevision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) ListDeleted(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SynchronizationResponse, error) {
+	out := new(SynchronizationResponse)
+	err := c.cc.Invoke(ctx, Gophkeeper_ListDeleted_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) Undelete(ctx context.Context, in *GetDataRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_Undelete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *gophkeeperClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Gophkeeper_Purge_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // GophkeeperServer is the server API for Gophkeeper service.
 // All implementations must embed UnimplementedGophkeeperServer
 // for forward compatibility
@@ -125,6 +389,32 @@ type GophkeeperServer interface {
 	Sync(context.Context, *emptypb.Empty) (*SynchronizationResponse, error)
 	ClientSync(context.Context, *ClientSyncRequest) (*emptypb.Empty, error)
 	DelData(context.Context, *GetDataRequest) (*emptypb.Empty, error)
+	Refresh(context.Context, *emptypb.Empty) (*SessionResponse, error)
+	Logout(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	VerifySecondFactor(context.Context, *SecondFactorRequest) (*AuthLoginResponse, error)
+	EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error)
+	ConfirmTOTP(context.Context, *TOTPCodeRequest) (*ConfirmTOTPResponse, error)
+	DisableTOTP(context.Context, *TOTPCodeRequest) (*emptypb.Empty, error)
+	// UnlockAccount requires admin-token metadata
+	UnlockAccount(context.Context, *UnlockRequest) (*emptypb.Empty, error)
+	ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error)
+	// ChangePassword revokes all other sessions of the user
+	ChangePassword(context.Context, *ChangePasswordRequest) (*emptypb.Empty, error)
+	UploadBlob(Gophkeeper_UploadBlobServer) error
+	DownloadBlob(*BlobRequest, Gophkeeper_DownloadBlobServer) error
+	// UploadStatus tells how to resume an interrupted upload
+	UploadStatus(context.Context, *BlobRequest) (*UploadStatusResponse, error)
+	// DeleteBlob erases blob which the record of its note doesn't reference anymore
+	DeleteBlob(context.Context, *BlobRequest) (*emptypb.Empty, error)
+	ListRevisions(context.Context, *GetDataRequest) (*ListRevisionsResponse, error)
+	GetRevision(context.Context, *RevisionRequest) (*GetDataResponse, error)
+	// RestoreRevision makes the revision the current version of the note, the replaced version becomes a revision too
+	RestoreRevision(context.Context, *RevisionRequest) (*emptypb.Empty, error)
+	// ListDeleted returns notes in the trash
+	ListDeleted(context.Context, *emptypb.Empty) (*SynchronizationResponse, error)
+	Undelete(context.Context, *GetDataRequest) (*emptypb.Empty, error)
+	// Purge erases notes in the trash with their revisions
+	Purge(context.Context, *PurgeRequest) (*emptypb.Empty, error)
 	mustEmbedUnimplementedGophkeeperServer()
 }
 
@@ -153,6 +443,63 @@ func (UnimplementedGophkeeperServer) ClientSync(context.Context, *ClientSyncRequ
 func (UnimplementedGophkeeperServer) DelData(context.Context, *GetDataRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DelData not implemented")
 }
+func (UnimplementedGophkeeperServer) Refresh(context.Context, *emptypb.Empty) (*SessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedGophkeeperServer) Logout(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedGophkeeperServer) VerifySecondFactor(context.Context, *SecondFactorRequest) (*AuthLoginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifySecondFactor not implemented")
+}
+func (UnimplementedGophkeeperServer) EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) ConfirmTOTP(context.Context, *TOTPCodeRequest) (*ConfirmTOTPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) DisableTOTP(context.Context, *TOTPCodeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTOTP not implemented")
+}
+func (UnimplementedGophkeeperServer) UnlockAccount(context.Context, *UnlockRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UnlockAccount not implemented")
+}
+func (UnimplementedGophkeeperServer) ListAuditEvents(context.Context, *ListAuditEventsRequest) (*ListAuditEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (UnimplementedGophkeeperServer) ChangePassword(context.Context, *ChangePasswordRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ChangePassword not implemented")
+}
+func (UnimplementedGophkeeperServer) UploadBlob(Gophkeeper_UploadBlobServer) error {
+	return status.Errorf(codes.Unimplemented, "method UploadBlob not implemented")
+}
+func (UnimplementedGophkeeperServer) DownloadBlob(*BlobRequest, Gophkeeper_DownloadBlobServer) error {
+	return status.Errorf(codes.Unimplemented, "method DownloadBlob not implemented")
+}
+func (UnimplementedGophkeeperServer) UploadStatus(context.Context, *BlobRequest) (*UploadStatusResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UploadStatus not implemented")
+}
+func (UnimplementedGophkeeperServer) DeleteBlob(context.Context, *BlobRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteBlob not implemented")
+}
+func (UnimplementedGophkeeperServer) ListRevisions(context.Context, *GetDataRequest) (*ListRevisionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRevisions not implemented")
+}
+func (UnimplementedGophkeeperServer) GetRevision(context.Context, *RevisionRequest) (*GetDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevision not implemented")
+}
+func (UnimplementedGophkeeperServer) RestoreRevision(context.Context, *RevisionRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreRevision not implemented")
+}
+func (UnimplementedGophkeeperServer) ListDeleted(context.Context, *emptypb.Empty) (*SynchronizationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeleted not implemented")
+}
+func (UnimplementedGophkeeperServer) Undelete(context.Context, *GetDataRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Undelete not implemented")
+}
+func (UnimplementedGophkeeperServer) Purge(context.Context, *PurgeRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Purge not implemented")
+}
 func (UnimplementedGophkeeperServer) mustEmbedUnimplementedGophkeeperServer() {}
 
 // UnsafeGophkeeperServer may be embedded to opt out of forward compatibility for this service.
@@ -292,6 +639,359 @@ func _Gophkeeper_DelData_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Gophkeeper_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_Refresh_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).Refresh(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).Logout(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_VerifySecondFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecondFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).VerifySecondFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_VerifySecondFactor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).VerifySecondFactor(ctx, req.(*SecondFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_EnrollTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).EnrollTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_EnrollTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).EnrollTOTP(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ConfirmTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ConfirmTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ConfirmTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ConfirmTOTP(ctx, req.(*TOTPCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_DisableTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).DisableTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_DisableTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).DisableTOTP(ctx, req.(*TOTPCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_UnlockAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).UnlockAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_UnlockAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).UnlockAccount(ctx, req.(*UnlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListAuditEventsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListAuditEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListAuditEvents(ctx, req.(*ListAuditEventsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ChangePassword_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ChangePasswordRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ChangePassword(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ChangePassword_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ChangePassword(ctx, req.(*ChangePasswordRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_UploadBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(GophkeeperServer).UploadBlob(&gophkeeperUploadBlobServer{stream})
+}
+
+type Gophkeeper_UploadBlobServer interface {
+	SendAndClose(*UploadBlobResponse) error
+	Recv() (*BlobChunk, error)
+	grpc.ServerStream
+}
+
+type gophkeeperUploadBlobServer struct {
+	grpc.ServerStream
+}
+
+func (x *gophkeeperUploadBlobServer) SendAndClose(m *UploadBlobResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *gophkeeperUploadBlobServer) Recv() (*BlobChunk, error) {
+	m := new(BlobChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Gophkeeper_DownloadBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(GophkeeperServer).DownloadBlob(m, &gophkeeperDownloadBlobServer{stream})
+}
+
+type Gophkeeper_DownloadBlobServer interface {
+	Send(*BlobChunk) error
+	grpc.ServerStream
+}
+
+type gophkeeperDownloadBlobServer struct {
+	grpc.ServerStream
+}
+
+func (x *gophkeeperDownloadBlobServer) Send(m *BlobChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Gophkeeper_UploadStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).UploadStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_UploadStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).UploadStatus(ctx, req.(*BlobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_DeleteBlob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).DeleteBlob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_DeleteBlob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).DeleteBlob(ctx, req.(*BlobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListRevisions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListRevisions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListRevisions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListRevisions(ctx, req.(*GetDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_GetRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).GetRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_GetRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).GetRevision(ctx, req.(*RevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_RestoreRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).RestoreRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_RestoreRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).RestoreRevision(ctx, req.(*RevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_ListDeleted_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).ListDeleted(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_ListDeleted_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).ListDeleted(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_Undelete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).Undelete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_Undelete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).Undelete(ctx, req.(*GetDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Gophkeeper_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GophkeeperServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Gophkeeper_Purge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GophkeeperServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Gophkeeper_ServiceDesc is the grpc.ServiceDesc for Gophkeeper service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -327,7 +1027,86 @@ var Gophkeeper_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DelData",
 			Handler:    _Gophkeeper_DelData_Handler,
 		},
+		{
+			MethodName: "Refresh",
+			Handler:    _Gophkeeper_Refresh_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _Gophkeeper_Logout_Handler,
+		},
+		{
+			MethodName: "VerifySecondFactor",
+			Handler:    _Gophkeeper_VerifySecondFactor_Handler,
+		},
+		{
+			MethodName: "EnrollTOTP",
+			Handler:    _Gophkeeper_EnrollTOTP_Handler,
+		},
+		{
+			MethodName: "ConfirmTOTP",
+			Handler:    _Gophkeeper_ConfirmTOTP_Handler,
+		},
+		{
+			MethodName: "DisableTOTP",
+			Handler:    _Gophkeeper_DisableTOTP_Handler,
+		},
+		{
+			MethodName: "UnlockAccount",
+			Handler:    _Gophkeeper_UnlockAccount_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _Gophkeeper_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "ChangePassword",
+			Handler:    _Gophkeeper_ChangePassword_Handler,
+		},
+		{
+			MethodName: "UploadStatus",
+			Handler:    _Gophkeeper_UploadStatus_Handler,
+		},
+		{
+			MethodName: "DeleteBlob",
+			Handler:    _Gophkeeper_DeleteBlob_Handler,
+		},
+		{
+			MethodName: "ListRevisions",
+			Handler:    _Gophkeeper_ListRevisions_Handler,
+		},
+		{
+			MethodName: "GetRevision",
+			Handler:    _Gophkeeper_GetRevision_Handler,
+		},
+		{
+			MethodName: "RestoreRevision",
+			Handler:    _Gophkeeper_RestoreRevision_Handler,
+		},
+		{
+			MethodName: "ListDeleted",
+			Handler:    _Gophkeeper_ListDeleted_Handler,
+		},
+		{
+			MethodName: "Undelete",
+			Handler:    _Gophkeeper_Undelete_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _Gophkeeper_Purge_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "UploadBlob",
+			Handler:       _Gophkeeper_UploadBlob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DownloadBlob",
+			Handler:       _Gophkeeper_DownloadBlob_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/handlers.proto",
 }
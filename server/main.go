@@ -4,20 +4,40 @@ import (
 	"log"
 	"net"
 
+	"gophkeeper/internal/config"
 	"gophkeeper/internal/grpcfuncs"
+	"gophkeeper/internal/tlsconfig"
 	pb "gophkeeper/proto"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
-	gophKeeper := grpcfuncs.NewGophKeeperServer()
-	listen, err := net.Listen("tcp", ":3200")
+	cfg := config.LoadServer()
+	gophKeeper := grpcfuncs.NewGophKeeperServer(cfg)
+	listen, err := net.Listen("tcp", cfg.Address)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	s := grpc.NewServer()
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(gophKeeper.UnaryAudit, gophKeeper.UnaryAuth), grpc.ChainStreamInterceptor(gophKeeper.StreamAuth)}
+	switch {
+	case cfg.Insecure:
+		if cfg.TLSCert != "" || cfg.TLSKey != "" || cfg.ClientCA != "" {
+			log.Fatal("-insecure can't be used with TLS certificates")
+		}
+		log.Println("insecure mode, server accepts plaintext connections")
+	case cfg.TLSCert == "" && cfg.TLSKey == "":
+		log.Fatal("TLS certificate is required: set -tls-cert and -tls-key, or -insecure to serve plaintext")
+	default:
+		tlsCfg, err := tlsconfig.Server(cfg.TLSCert, cfg.TLSKey, cfg.TLSMinVersion, cfg.ClientCA)
+		if err != nil {
+			log.Fatalf("err loading TLS config: %v", err)
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	s := grpc.NewServer(opts...)
 	pb.RegisterGophkeeperServer(s, &gophKeeper)
 
 	if err = s.Serve(listen); err != nil {